@@ -0,0 +1,386 @@
+package algorithm
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	DefaultMemoryBudget = 64 << 20
+	DefaultMaxFanIn     = 64
+)
+
+// RecordReader returns io.EOF once the input is exhausted,
+// size is the approximate number of bytes the record occupies in memory
+type RecordReader interface {
+	Read() (record interface{}, size int, e error)
+}
+
+type RecordWriter interface {
+	Write(record interface{}) error
+	Flush() error
+}
+
+// RecordCodec decides how records are laid out in the input, the output and the spilled runs
+type RecordCodec interface {
+	NewReader(r io.Reader) RecordReader
+	NewWriter(w io.Writer) RecordWriter
+}
+
+// CSVCodec reads and writes records as []string
+type CSVCodec struct {
+	Comma rune
+}
+
+type csvRecordReader struct {
+	reader *csv.Reader
+}
+
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+func (codec CSVCodec) NewReader(r io.Reader) RecordReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if codec.Comma != 0 {
+		reader.Comma = codec.Comma
+	}
+	return &csvRecordReader{reader}
+}
+
+func (codec CSVCodec) NewWriter(w io.Writer) RecordWriter {
+	writer := csv.NewWriter(w)
+	if codec.Comma != 0 {
+		writer.Comma = codec.Comma
+	}
+	return &csvRecordWriter{writer}
+}
+
+func (r *csvRecordReader) Read() (interface{}, int, error) {
+	record, e := r.reader.Read()
+	if e != nil {
+		return nil, 0, e
+	}
+	size := 24
+	for i := range record {
+		size += len(record[i]) + 16
+	}
+	return record, size, nil
+}
+
+func (w *csvRecordWriter) Write(record interface{}) error {
+	return w.writer.Write(record.([]string))
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// JSONLineCodec reads one JSON document per line. New returns the pointer every line is decoded into,
+// when it is nil lines are decoded into map[string]interface{}.
+type JSONLineCodec struct {
+	New func() interface{}
+}
+
+type jsonRecordReader struct {
+	reader *bufio.Reader
+	create func() interface{}
+}
+
+type jsonRecordWriter struct {
+	writer *bufio.Writer
+}
+
+func (codec JSONLineCodec) NewReader(r io.Reader) RecordReader {
+	create := codec.New
+	if create == nil {
+		create = func() interface{} {
+			return &map[string]interface{}{}
+		}
+	}
+	return &jsonRecordReader{bufio.NewReader(r), create}
+}
+
+func (codec JSONLineCodec) NewWriter(w io.Writer) RecordWriter {
+	return &jsonRecordWriter{bufio.NewWriter(w)}
+}
+
+func (r *jsonRecordReader) Read() (interface{}, int, error) {
+	for {
+		line, e := r.reader.ReadBytes('\n')
+		if e != nil && e != io.EOF {
+			return nil, 0, e
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			record := r.create()
+			if e := json.Unmarshal(trimmed, record); e != nil {
+				return nil, 0, e
+			}
+			return record, len(line), nil
+		}
+		if e == io.EOF {
+			return nil, 0, io.EOF
+		}
+	}
+}
+
+func (w *jsonRecordWriter) Write(record interface{}) error {
+	line, e := json.Marshal(record)
+	if e != nil {
+		return e
+	}
+	if _, e := w.writer.Write(line); e != nil {
+		return e
+	}
+	return w.writer.WriteByte('\n')
+}
+
+func (w *jsonRecordWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// ExternalSorter sorts inputs larger than memory: records are collected until MemoryBudget is reached,
+// each batch is sorted and spilled to a temporary run file, and the runs are k-way merged into the output
+type ExternalSorter struct {
+	Codec        RecordCodec
+	Less         func(a, b interface{}) bool
+	MemoryBudget int
+	MaxFanIn     int
+	TempDir      string
+}
+
+func NewExternalSorter(codec RecordCodec, less func(a, b interface{}) bool, memoryBudget int) *ExternalSorter {
+	return &ExternalSorter{
+		Codec:        codec,
+		Less:         less,
+		MemoryBudget: memoryBudget,
+		MaxFanIn:     DefaultMaxFanIn,
+	}
+}
+
+// Sort reads every record from r and writes them to w in order, equal records keep their input order
+func (sorter *ExternalSorter) Sort(r io.Reader, w io.Writer) (e error) {
+	budget := sorter.MemoryBudget
+	if budget <= 0 {
+		budget = DefaultMemoryBudget
+	}
+
+	// Every temporary file ever created, runs are removed eagerly once merged
+	created := make([]string, 0)
+	defer func() {
+		for i := range created {
+			if err := os.Remove(created[i]); err != nil && !os.IsNotExist(err) && e == nil {
+				e = err
+			}
+		}
+	}()
+	runs := make([]string, 0)
+
+	reader := sorter.Codec.NewReader(r)
+	records := make([]interface{}, 0)
+	used := 0
+	for {
+		record, size, e := reader.Read()
+		if e == io.EOF {
+			break
+		} else if e != nil {
+			return e
+		}
+		records = append(records, record)
+		used += size
+		if used >= budget {
+			run, e := sorter.spill(records)
+			if e != nil {
+				return e
+			}
+			created = append(created, run)
+			runs = append(runs, run)
+			records = records[:0]
+			used = 0
+		}
+	}
+
+	// Everything fitted in memory, no need to touch the disk
+	if len(runs) == 0 {
+		sorter.sortRecords(records)
+		return sorter.writeRecords(records, w)
+	}
+	if len(records) > 0 {
+		run, e := sorter.spill(records)
+		if e != nil {
+			return e
+		}
+		created = append(created, run)
+		runs = append(runs, run)
+	}
+
+	fanIn := sorter.MaxFanIn
+	if fanIn < 2 {
+		fanIn = DefaultMaxFanIn
+	}
+	// Merge consecutive groups of runs level by level, keeping runs in input order
+	for len(runs) > fanIn {
+		next := make([]string, 0, len(runs)/fanIn+1)
+		for i := 0; i < len(runs); i += fanIn {
+			end := i + fanIn
+			if end > len(runs) {
+				end = len(runs)
+			}
+			if end-i == 1 {
+				next = append(next, runs[i])
+				continue
+			}
+			file, e := ioutil.TempFile(sorter.TempDir, "external_sort_")
+			if e != nil {
+				return e
+			}
+			created = append(created, file.Name())
+			if e := sorter.mergeRuns(runs[i:end], file); e != nil {
+				_ = file.Close()
+				return e
+			}
+			if e := file.Close(); e != nil {
+				return e
+			}
+			for j := i; j < end; j++ {
+				if e := os.Remove(runs[j]); e != nil {
+					return e
+				}
+			}
+			next = append(next, file.Name())
+		}
+		runs = next
+	}
+	return sorter.mergeRuns(runs, w)
+}
+
+func (sorter *ExternalSorter) sortRecords(records []interface{}) {
+	SliceStable(records, func(i, j int) bool {
+		return sorter.Less(records[i], records[j])
+	})
+}
+
+func (sorter *ExternalSorter) writeRecords(records []interface{}, w io.Writer) error {
+	writer := sorter.Codec.NewWriter(w)
+	for i := range records {
+		if e := writer.Write(records[i]); e != nil {
+			return e
+		}
+	}
+	return writer.Flush()
+}
+
+func (sorter *ExternalSorter) spill(records []interface{}) (string, error) {
+	sorter.sortRecords(records)
+	file, e := ioutil.TempFile(sorter.TempDir, "external_sort_")
+	if e != nil {
+		return "", e
+	}
+	if e := sorter.writeRecords(records, file); e != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", e
+	}
+	if e := file.Close(); e != nil {
+		_ = os.Remove(file.Name())
+		return "", e
+	}
+	return file.Name(), nil
+}
+
+type runCursor struct {
+	run    int
+	record interface{}
+	reader RecordReader
+}
+
+type mergeHeap struct {
+	cursors []*runCursor
+	less    func(a, b interface{}) bool
+}
+
+func (h *mergeHeap) Len() int {
+	return len(h.cursors)
+}
+
+// Less breaks ties by run index, runs are created in input order so the merge stays stable
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.cursors[i], h.cursors[j]
+	if h.less(a.record, b.record) {
+		return true
+	}
+	if h.less(b.record, a.record) {
+		return false
+	}
+	return a.run < b.run
+}
+
+func (h *mergeHeap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.cursors = append(h.cursors, x.(*runCursor))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.cursors)
+	cursor := h.cursors[n-1]
+	h.cursors = h.cursors[:n-1]
+	return cursor
+}
+
+func (sorter *ExternalSorter) mergeRuns(runs []string, w io.Writer) (e error) {
+	files := make([]*os.File, 0, len(runs))
+	defer func() {
+		for i := range files {
+			if err := files[i].Close(); err != nil && e == nil {
+				e = err
+			}
+		}
+	}()
+
+	candidates := &mergeHeap{make([]*runCursor, 0, len(runs)), sorter.Less}
+	for i := range runs {
+		file, e := os.Open(runs[i])
+		if e != nil {
+			return e
+		}
+		files = append(files, file)
+		reader := sorter.Codec.NewReader(bufio.NewReader(file))
+		record, _, e := reader.Read()
+		if e == io.EOF {
+			continue
+		} else if e != nil {
+			return e
+		}
+		candidates.cursors = append(candidates.cursors, &runCursor{i, record, reader})
+	}
+	heap.Init(candidates)
+
+	writer := sorter.Codec.NewWriter(w)
+	for candidates.Len() > 0 {
+		cursor := candidates.cursors[0]
+		if e := writer.Write(cursor.record); e != nil {
+			return e
+		}
+		record, _, e := cursor.reader.Read()
+		if e == io.EOF {
+			heap.Pop(candidates)
+			continue
+		} else if e != nil {
+			return e
+		}
+		cursor.record = record
+		heap.Fix(candidates, 0)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,142 @@
+package algorithm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// csvRecords returns n records of a key with few distinct values and the position in the input
+func csvRecords(random *rand.Rand, n, keys int) [][]string {
+	records := make([][]string, n)
+	for i := range records {
+		records[i] = []string{strconv.Itoa(random.Intn(keys)), strconv.Itoa(i)}
+	}
+	return records
+}
+
+func encodeCSV(t testing.TB, records [][]string) []byte {
+	var buffer bytes.Buffer
+	if e := csv.NewWriter(&buffer).WriteAll(records); e != nil {
+		t.Fatal(e)
+	}
+	return buffer.Bytes()
+}
+
+func lessByKey(a, b interface{}) bool {
+	x, _ := strconv.Atoi(a.([]string)[0])
+	y, _ := strconv.Atoi(b.([]string)[0])
+	return x < y
+}
+
+// externalSortCSV sorts records by key in a fresh temporary directory and checks nothing is left behind
+func externalSortCSV(t *testing.T, records [][]string, budget, fanIn int) [][]string {
+	dir, e := ioutil.TempDir("", "external_sort")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	sorter := NewExternalSorter(CSVCodec{}, lessByKey, budget)
+	sorter.MaxFanIn, sorter.TempDir = fanIn, dir
+
+	var output bytes.Buffer
+	if e := sorter.Sort(bytes.NewReader(encodeCSV(t, records)), &output); e != nil {
+		t.Fatal(e)
+	}
+	if left, _ := ioutil.ReadDir(dir); len(left) != 0 {
+		t.Errorf("%d temporary files left behind", len(left))
+	}
+	sorted, e := csv.NewReader(&output).ReadAll()
+	if e != nil {
+		t.Fatal(e)
+	}
+	return sorted
+}
+
+func TestExternalSortMatchesSliceStable(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		n, keys, budget, fanIn int
+	}{
+		// Runs of a few records, merged over several levels
+		{500, 7, 200, 2},
+		{500, 7, 200, 4},
+		{1000, 3, 300, 16},
+		{1000, 1000, 1000, 3},
+		// A single run spilled with a last partial one
+		{10, 2, 400, 2},
+	} {
+		t.Run(fmt.Sprintf("%d records %d keys budget %d fan-in %d", test.n, test.keys, test.budget, test.fanIn), func(t *testing.T) {
+			records := csvRecords(random, test.n, test.keys)
+			expected := append([][]string(nil), records...)
+			sort.SliceStable(expected, func(i, j int) bool { return lessByKey(expected[i], expected[j]) })
+			sorted := externalSortCSV(t, records, test.budget, test.fanIn)
+			if len(sorted) != len(expected) {
+				t.Fatalf("got %d records, want %d", len(sorted), len(expected))
+			}
+			for i := range expected {
+				if strings.Join(sorted[i], ",") != strings.Join(expected[i], ",") {
+					t.Fatalf("record %d: got %v, want %v", i, sorted[i], expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExternalSortEmptyInput(t *testing.T) {
+	for _, budget := range []int{0, 1} {
+		if sorted := externalSortCSV(t, nil, budget, 2); len(sorted) != 0 {
+			t.Errorf("budget %d: got %v from empty input", budget, sorted)
+		}
+	}
+}
+
+func TestExternalSortSingleChunk(t *testing.T) {
+	records := csvRecords(rand.New(rand.NewSource(2)), 300, 5)
+	expected := append([][]string(nil), records...)
+	sort.SliceStable(expected, func(i, j int) bool { return lessByKey(expected[i], expected[j]) })
+	// The default budget keeps everything in memory
+	sorted := externalSortCSV(t, records, 0, 0)
+	for i := range expected {
+		if strings.Join(sorted[i], ",") != strings.Join(expected[i], ",") {
+			t.Fatalf("record %d: got %v, want %v", i, sorted[i], expected[i])
+		}
+	}
+}
+
+func TestExternalSortJSONLines(t *testing.T) {
+	input := "{\"k\":2,\"p\":0}\n\n{\"k\":1,\"p\":1}\n{\"k\":2,\"p\":2}\n{\"k\":1,\"p\":3}\n"
+	less := func(a, b interface{}) bool {
+		return (*a.(*map[string]interface{}))["k"].(float64) < (*b.(*map[string]interface{}))["k"].(float64)
+	}
+	var output bytes.Buffer
+	if e := NewExternalSorter(JSONLineCodec{}, less, 1).Sort(strings.NewReader(input), &output); e != nil {
+		t.Fatal(e)
+	}
+	expected := "{\"k\":1,\"p\":1}\n{\"k\":1,\"p\":3}\n{\"k\":2,\"p\":0}\n{\"k\":2,\"p\":2}\n"
+	if output.String() != expected {
+		t.Errorf("got\n%s\nwant\n%s", output.String(), expected)
+	}
+}
+
+func BenchmarkExternalSort(b *testing.B) {
+	input := encodeCSV(b, csvRecords(rand.New(rand.NewSource(3)), 100000, 1000))
+	for _, budget := range []int{1 << 20, 64 << 20} {
+		b.Run(fmt.Sprintf("budget %dKB", budget>>10), func(b *testing.B) {
+			sorter := NewExternalSorter(CSVCodec{}, lessByKey, budget)
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if e := sorter.Sort(bytes.NewReader(input), ioutil.Discard); e != nil {
+					b.Fatal(e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package algorithm
+
+import (
+	"go-utils/src/concurrency"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// DefaultParallelThreshold is the smallest range worth handing to another worker
+const DefaultParallelThreshold = 1 << 13
+
+// ParallelSort is an introsort which hands one side of every partition to the pool
+// until ranges get shorter than threshold, those are sorted by whoever partitioned them.
+// Ranges are never shared, so data only has to support concurrent access to distinct indices.
+// It is not stable.
+func ParallelSort(data sort.Interface, pool *concurrency.RoutinesPool, threshold int) {
+	if threshold <= 0 {
+		threshold = DefaultParallelThreshold
+	}
+	n := data.Len()
+	if n <= threshold {
+		IntroSort(data)
+		return
+	}
+
+	var pending sync.WaitGroup
+	var sortRange func(a, b, depth int)
+	sortRange = func(a, b, depth int) {
+		defer pending.Done()
+		for b-a > threshold {
+			if depth == 0 {
+				heapSort(data, a, b)
+				return
+			}
+			depth--
+			p := partition(data, a, b, choosePivot(data, a, b))
+			// Hand over the smaller side and keep partitioning the larger one
+			lo, hi := a, p
+			if p-a < b-p {
+				a = p + 1
+			} else {
+				lo, hi = p+1, b
+				b = p
+			}
+			pending.Add(1)
+			taskDepth := depth
+			task := func() {
+				sortRange(lo, hi, taskDepth)
+			}
+			// Waiting for room could deadlock once every worker is a submitter,
+			// so a rejected range is sorted right here, and a discarded one by the fallback
+			if e := pool.TrySubmitOrElse(task, func(error) { task() }); e != nil {
+				task()
+			}
+		}
+		introSort(data, a, b, depth)
+	}
+	pending.Add(1)
+	sortRange(0, n, maxDepth(n))
+	pending.Wait()
+}
+
+// ParallelSortInts sorts array with one worker per CPU
+func ParallelSortInts(array []int, threshold int) {
+	pool := concurrency.NewRoutinesPool(runtime.NumCPU())
+	ParallelSort(sort.IntSlice(array), pool, threshold)
+	pool.Close()
+}
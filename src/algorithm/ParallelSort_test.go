@@ -0,0 +1,53 @@
+package algorithm
+
+import (
+	"go-utils/src/concurrency"
+	"math/rand"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestParallelSort(t *testing.T) {
+	random := rand.New(rand.NewSource(6))
+	for _, n := range []int{0, 5, 100, 1000, 12345, 300000} {
+		for _, max := range []int{3, 1000000} {
+			array := randomInts(random, n, max)
+			ParallelSortInts(array, 97)
+			if !sort.IntsAreSorted(array) {
+				t.Fatalf("%d elements below %d are not sorted", n, max)
+			}
+		}
+	}
+}
+
+func TestParallelSortSmallPool(t *testing.T) {
+	// Ranges which do not fit in the queue are sorted by the submitter
+	pool := concurrency.NewDynamicRoutinesPool(1, 1, 1, 0)
+	defer pool.Close()
+	array := randomInts(rand.New(rand.NewSource(7)), 100000, 1000000)
+	ParallelSort(sort.IntSlice(array), pool, 100)
+	if !sort.IntsAreSorted(array) {
+		t.Fatal("array is not sorted")
+	}
+}
+
+func TestParallelSortDiscardingPool(t *testing.T) {
+	// Discarded ranges are sorted by the fallback instead of being lost
+	for _, policy := range []concurrency.RejectionPolicy{concurrency.DiscardPolicy, concurrency.DiscardOldestPolicy} {
+		pool := concurrency.NewDynamicRoutinesPool(1, 1, 1, 0)
+		pool.SetRejectionPolicy(policy)
+		array := randomInts(rand.New(rand.NewSource(8)), 100000, 1000000)
+		ParallelSort(sort.IntSlice(array), pool, 100)
+		if !sort.IntsAreSorted(array) {
+			t.Fatal("array is not sorted")
+		}
+		pool.Close()
+	}
+}
+
+func BenchmarkParallelSort(b *testing.B) {
+	pool := concurrency.NewRoutinesPool(runtime.NumCPU())
+	defer pool.Close()
+	benchmarkSort(b, func(array []int) { ParallelSort(sort.IntSlice(array), pool, 0) })
+}
@@ -0,0 +1,82 @@
+package algorithm
+
+const radix = 256
+
+// RadixSortInts is a LSD radix sort taking one byte per pass
+func RadixSortInts(array []int) {
+	if len(array) < 2 {
+		return
+	}
+	buffer := make([]int, len(array))
+	source, destination := array, buffer
+	for shift := uint(0); shift < 64; shift += 8 {
+		var count [radix + 1]int
+		for i := range source {
+			count[radixDigit(source[i], shift)+1]++
+		}
+		// Every value shares this digit, nothing to move
+		if count[radixDigit(source[0], shift)+1] == len(source) {
+			continue
+		}
+		for i := 1; i <= radix; i++ {
+			count[i] += count[i-1]
+		}
+		for i := range source {
+			digit := radixDigit(source[i], shift)
+			destination[count[digit]] = source[i]
+			count[digit]++
+		}
+		source, destination = destination, source
+	}
+	if &source[0] != &array[0] {
+		copy(array, source)
+	}
+}
+
+// radixDigit flips the sign bit so negative values are ordered before positive ones
+func radixDigit(value int, shift uint) int {
+	return int((uint64(value) ^ 1<<63) >> shift & (radix - 1))
+}
+
+// RadixSortStrings is a MSD radix sort ordering strings byte by byte
+func RadixSortStrings(array []string) {
+	radixSortStrings(array, make([]string, len(array)), 0)
+}
+
+func charAt(s string, depth int) int {
+	if depth < len(s) {
+		return int(s[depth])
+	}
+	return -1
+}
+
+func radixSortStrings(array, buffer []string, depth int) {
+	if len(array) <= 16 {
+		for i := 1; i < len(array); i++ {
+			for j := i; j > 0 && array[j] < array[j-1]; j-- {
+				array[j], array[j-1] = array[j-1], array[j]
+			}
+		}
+		return
+	}
+	// Bucket 0 holds strings shorter than depth, bucket c+1 holds byte c
+	var count [radix + 2]int
+	for i := range array {
+		count[charAt(array[i], depth)+2]++
+	}
+	for i := 1; i < len(count); i++ {
+		count[i] += count[i-1]
+	}
+	for i := range array {
+		bucket := charAt(array[i], depth) + 1
+		buffer[count[bucket]] = array[i]
+		count[bucket]++
+	}
+	copy(array, buffer[:len(array)])
+	for bucket := 1; bucket <= radix; bucket++ {
+		lo, hi := count[bucket-1], count[bucket]
+		if hi-lo > 1 {
+			radixSortStrings(array[lo:hi], buffer[lo:hi], depth+1)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package algorithm
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRadixSortIntsMatchesSortSlice(t *testing.T) {
+	inputs := append(testInputs(), []int{math.MaxInt64, math.MinInt64, 0, -1, 1, math.MinInt64})
+	for _, input := range inputs {
+		expected := append([]int(nil), input...)
+		sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+		actual := append([]int(nil), input...)
+		RadixSortInts(actual)
+		if !equalInts(actual, expected) {
+			t.Fatalf("%d elements: got %v, want %v", len(input), actual, expected)
+		}
+	}
+}
+
+func randomStrings(random *rand.Rand, n, maxLength int, alphabet string) []string {
+	array := make([]string, n)
+	for i := range array {
+		bytes := make([]byte, random.Intn(maxLength+1))
+		for k := range bytes {
+			bytes[k] = alphabet[random.Intn(len(alphabet))]
+		}
+		array[i] = string(bytes)
+	}
+	return array
+}
+
+func TestRadixSortStringsMatchesSortSlice(t *testing.T) {
+	random := rand.New(rand.NewSource(4))
+	for n := 0; n < 600; n += 7 {
+		for _, alphabet := range []string{"ab", "abcdefghijklmnopqrstuvwxyz", "\x00\xff\x80a"} {
+			input := randomStrings(random, n, 6, alphabet)
+			expected := append([]string(nil), input...)
+			sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+			RadixSortStrings(input)
+			for i := range input {
+				if input[i] != expected[i] {
+					t.Fatalf("%d strings over %q: got %q at %d, want %q", n, alphabet, input[i], i, expected[i])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkRadixSortInts(b *testing.B) {
+	benchmarkSort(b, RadixSortInts)
+}
+
+func BenchmarkRadixSortStrings(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			input := randomStrings(rand.New(rand.NewSource(5)), size.n, 16, "abcdefghijklmnopqrstuvwxyz")
+			array := make([]string, size.n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(array, input)
+				b.StartTimer()
+				RadixSortStrings(array)
+			}
+		})
+	}
+}
+
+func BenchmarkSortStrings(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			input := randomStrings(rand.New(rand.NewSource(5)), size.n, 16, "abcdefghijklmnopqrstuvwxyz")
+			array := make([]string, size.n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(array, input)
+				b.StartTimer()
+				sort.Strings(array)
+			}
+		})
+	}
+}
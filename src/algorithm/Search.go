@@ -0,0 +1,99 @@
+package algorithm
+
+// The binary searches below work on any sorted sequence of n elements,
+// compare(i) reports element i against the target as a negative number, zero or a positive number
+
+// LowerBound returns the first index whose element is not less than the target, or n
+func LowerBound(n int, compare func(i int) int) int {
+	lo, hi := 0, n
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if compare(m) < 0 {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return lo
+}
+
+// UpperBound returns the first index whose element is greater than the target, or n
+func UpperBound(n int, compare func(i int) int) int {
+	lo, hi := 0, n
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if compare(m) <= 0 {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return lo
+}
+
+// EqualRange returns [lo, hi) holding every element equal to the target
+func EqualRange(n int, compare func(i int) int) (int, int) {
+	lo := LowerBound(n, compare)
+	hi := lo + UpperBound(n-lo, func(i int) int {
+		return compare(lo + i)
+	})
+	return lo, hi
+}
+
+// BinarySearch returns the index of the first element equal to the target
+func BinarySearch(n int, compare func(i int) int) (int, bool) {
+	i := LowerBound(n, compare)
+	return i, i < n && compare(i) == 0
+}
+
+// ExponentialSearch finds the lower bound in a sequence whose length is unknown,
+// such as a stream being consumed. compare returns false once i is past the end.
+// It probes indices 0, 1, 3, 7, ... to bracket the target, then binary searches the bracket.
+func ExponentialSearch(compare func(i int) (int, bool)) int {
+	lo, hi := 0, 1
+	for {
+		c, ok := compare(hi - 1)
+		if !ok || c >= 0 {
+			break
+		}
+		lo = hi
+		hi *= 2
+	}
+	hi--
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if c, ok := compare(m); ok && c < 0 {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return lo
+}
+
+// InterpolationSearch returns an index of target in the ascending array, or -1.
+// It takes O(log(log(n))) probes when values are uniformly distributed.
+func InterpolationSearch(array []int, target int) int {
+	lo, hi := 0, len(array)-1
+	for lo <= hi && array[lo] <= target && target <= array[hi] {
+		if array[lo] == array[hi] {
+			return lo
+		}
+		// Differences are taken in float64, they overflow int for keys spanning more than half its range
+		offset := (float64(target) - float64(array[lo])) / (float64(array[hi]) - float64(array[lo])) * float64(hi-lo)
+		position := lo + int(offset)
+		if position < lo {
+			position = lo
+		} else if position > hi {
+			position = hi
+		}
+		if array[position] == target {
+			return position
+		} else if array[position] < target {
+			lo = position + 1
+		} else {
+			hi = position - 1
+		}
+	}
+	return -1
+}
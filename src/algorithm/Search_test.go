@@ -0,0 +1,63 @@
+package algorithm
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSearchesMatchSortSearch(t *testing.T) {
+	random := rand.New(rand.NewSource(12))
+	for n := 0; n < 200; n += 3 {
+		array := randomInts(random, n, 40)
+		sort.Ints(array)
+		for target := -22; target < 22; target++ {
+			compare := func(i int) int {
+				return array[i] - target
+			}
+			lower, upper := sort.SearchInts(array, target), sort.SearchInts(array, target+1)
+			if l := LowerBound(n, compare); l != lower {
+				t.Fatalf("LowerBound(%d) = %d, want %d in %v", target, l, lower, array)
+			}
+			if u := UpperBound(n, compare); u != upper {
+				t.Fatalf("UpperBound(%d) = %d, want %d in %v", target, u, upper, array)
+			}
+			if l, u := EqualRange(n, compare); l != lower || u != upper {
+				t.Fatalf("EqualRange(%d) = [%d, %d), want [%d, %d)", target, l, u, lower, upper)
+			}
+			if i, found := BinarySearch(n, compare); found != (lower < upper) || found && array[i] != target {
+				t.Fatalf("BinarySearch(%d) = %d, %v in %v", target, i, found, array)
+			}
+			stream := func(i int) (int, bool) {
+				if i >= n {
+					return 0, false
+				}
+				return array[i] - target, true
+			}
+			if i := ExponentialSearch(stream); i != lower {
+				t.Fatalf("ExponentialSearch(%d) = %d, want %d in %v", target, i, lower, array)
+			}
+			if i := InterpolationSearch(array, target); (i < 0) != (lower == upper) || i >= 0 && array[i] != target {
+				t.Fatalf("InterpolationSearch(%d) = %d in %v", target, i, array)
+			}
+		}
+	}
+}
+
+func TestInterpolationSearchExtremeKeys(t *testing.T) {
+	array := []int{math.MinInt64, math.MinInt64 + 1, -1, 0, 1, math.MaxInt64 - 1, math.MaxInt64}
+	for i, target := range array {
+		if position := InterpolationSearch(array, target); position != i {
+			t.Errorf("InterpolationSearch(%d) = %d, want %d", target, position, i)
+		}
+	}
+	for _, target := range []int{-2, 2, math.MaxInt64 - 2} {
+		if position := InterpolationSearch(array, target); position != -1 {
+			t.Errorf("InterpolationSearch(%d) = %d, want -1", target, position)
+		}
+	}
+	if position := InterpolationSearch([]int{math.MinInt64, 0, math.MaxInt64}, 0); position != 1 {
+		t.Errorf("got %d, want 1", position)
+	}
+}
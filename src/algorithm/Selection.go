@@ -0,0 +1,97 @@
+package algorithm
+
+import "sort"
+
+// NthElement rearranges data so that data[n] is the element which would be there if data was sorted,
+// everything before it is not greater and everything after it is not less.
+// It is a quickselect which falls back to heap sort when partitioning keeps going badly.
+func NthElement(data sort.Interface, n int) {
+	length := data.Len()
+	if n < 0 || n >= length {
+		return
+	}
+	a, b, depth := 0, length, maxDepth(length)
+	for b-a > insertionSortThreshold {
+		if depth == 0 {
+			heapSort(data, a, b)
+			return
+		}
+		depth--
+		p := partition(data, a, b, choosePivot(data, a, b))
+		if p == n {
+			return
+		} else if n < p {
+			b = p
+		} else {
+			a = p + 1
+		}
+	}
+	insertionSort(data, a, b)
+}
+
+// PartialSort sorts the k smallest elements into data[0, k), the order of the rest is unspecified
+func PartialSort(data sort.Interface, k int) {
+	n := data.Len()
+	if k > n {
+		k = n
+	}
+	if k <= 0 {
+		return
+	}
+	NthElement(data, k-1)
+	introSort(data, 0, k-1, maxDepth(k-1))
+}
+
+// TopK returns the indices of the k greatest of n elements in descending order without moving them,
+// equal elements keep the order of their indices. Only a heap of k indices is kept in memory.
+func TopK(n, k int, less func(i, j int) bool) []int {
+	if k > n {
+		k = n
+	}
+	if k <= 0 {
+		return []int{}
+	}
+
+	// Min-heap of candidates, the root is the weakest of the current top k
+	weaker := func(i, j int) bool {
+		return less(i, j) || !less(j, i) && i > j
+	}
+	candidates := make([]int, 0, k)
+	down := func(root int) {
+		for {
+			child := 2*root + 1
+			if child >= len(candidates) {
+				return
+			}
+			if child+1 < len(candidates) && weaker(candidates[child+1], candidates[child]) {
+				child++
+			}
+			if !weaker(candidates[child], candidates[root]) {
+				return
+			}
+			candidates[root], candidates[child] = candidates[child], candidates[root]
+			root = child
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if len(candidates) < k {
+			candidates = append(candidates, i)
+			for j := len(candidates) - 1; j > 0 && weaker(candidates[j], candidates[(j-1)/2]); j = (j - 1) / 2 {
+				candidates[j], candidates[(j-1)/2] = candidates[(j-1)/2], candidates[j]
+			}
+		} else if less(candidates[0], i) {
+			candidates[0] = i
+			down(0)
+		}
+	}
+
+	result := make([]int, len(candidates))
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = candidates[0]
+		candidates[0] = candidates[len(candidates)-1]
+		candidates = candidates[:len(candidates)-1]
+		down(0)
+	}
+	return result
+}
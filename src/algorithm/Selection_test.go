@@ -0,0 +1,179 @@
+package algorithm
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// countingInts counts comparisons, to catch inputs degrading selection to quadratic time
+type countingInts struct {
+	sort.IntSlice
+	comparisons int
+}
+
+func (data *countingInts) Less(i, j int) bool {
+	data.comparisons++
+	return data.IntSlice.Less(i, j)
+}
+
+// adversarialInputs returns patterns known to defeat naive pivot choices, all of n elements
+func adversarialInputs(n int) map[string][]int {
+	inputs := map[string][]int{
+		"sorted":    make([]int, n),
+		"reversed":  make([]int, n),
+		"equal":     make([]int, n),
+		"organPipe": make([]int, n),
+		"sawtooth":  make([]int, n),
+		"twoValues": make([]int, n),
+	}
+	for i := 0; i < n; i++ {
+		inputs["sorted"][i] = i
+		inputs["reversed"][i] = n - i
+		inputs["equal"][i] = 7
+		if i < n/2 {
+			inputs["organPipe"][i] = i
+		} else {
+			inputs["organPipe"][i] = n - i
+		}
+		inputs["sawtooth"][i] = i % 17
+		inputs["twoValues"][i] = i % 2
+	}
+	return inputs
+}
+
+func checkNthElement(t *testing.T, name string, input []int, n int) {
+	expected := append([]int(nil), input...)
+	sort.Ints(expected)
+	data := &countingInts{IntSlice: append([]int(nil), input...)}
+	NthElement(data, n)
+	actual := data.IntSlice
+	if actual[n] != expected[n] {
+		t.Fatalf("%s: element %d of %d is %d, want %d", name, n, len(input), actual[n], expected[n])
+	}
+	for i := 0; i < n; i++ {
+		if actual[i] > actual[n] {
+			t.Fatalf("%s: %d before position %d is greater than %d", name, actual[i], n, actual[n])
+		}
+	}
+	for i := n + 1; i < len(actual); i++ {
+		if actual[i] < actual[n] {
+			t.Fatalf("%s: %d after position %d is less than %d", name, actual[i], n, actual[n])
+		}
+	}
+	// Quickselect is linear on average, the heap sort fallback bounds it by O(n*log(n))
+	if limit := 4 * len(input) * maxDepth(len(input)); len(input) > 0 && data.comparisons > limit {
+		t.Fatalf("%s: %d comparisons for %d elements", name, data.comparisons, len(input))
+	}
+}
+
+func TestNthElementWithDuplicates(t *testing.T) {
+	random := rand.New(rand.NewSource(8))
+	for n := 1; n < 400; n += 13 {
+		for _, max := range []int{2, 5, 1000} {
+			input := randomInts(random, n, max)
+			for _, k := range []int{0, n / 2, n - 1} {
+				checkNthElement(t, "random", input, k)
+			}
+		}
+	}
+}
+
+func TestNthElementAdversarial(t *testing.T) {
+	n := 100000
+	for name, input := range adversarialInputs(n) {
+		for _, k := range []int{0, n / 3, n / 2, n - 1} {
+			checkNthElement(t, name, input, k)
+		}
+	}
+}
+
+func TestNthElementOutOfRange(t *testing.T) {
+	array := []int{3, 1, 2}
+	NthElement(sort.IntSlice(array), -1)
+	NthElement(sort.IntSlice(array), 3)
+	NthElement(sort.IntSlice(nil), 0)
+	if !equalInts(array, []int{3, 1, 2}) {
+		t.Fatalf("got %v", array)
+	}
+}
+
+func TestPartialSort(t *testing.T) {
+	random := rand.New(rand.NewSource(9))
+	inputs := adversarialInputs(5000)
+	inputs["random"] = randomInts(random, 5000, 10)
+	for name, input := range inputs {
+		expected := append([]int(nil), input...)
+		sort.Ints(expected)
+		for _, k := range []int{-1, 0, 1, 20, 2500, 5000, 6000} {
+			actual := append([]int(nil), input...)
+			PartialSort(sort.IntSlice(actual), k)
+			sorted := k
+			if sorted > len(input) {
+				sorted = len(input)
+			}
+			if sorted > 0 && !equalInts(actual[:sorted], expected[:sorted]) {
+				t.Fatalf("%s: first %d elements are %v", name, k, actual[:sorted])
+			}
+			sort.Ints(actual)
+			if !equalInts(actual, expected) {
+				t.Fatalf("%s: PartialSort(%d) lost elements", name, k)
+			}
+		}
+	}
+}
+
+func TestTopK(t *testing.T) {
+	random := rand.New(rand.NewSource(10))
+	inputs := adversarialInputs(3000)
+	inputs["random"] = randomInts(random, 3000, 10)
+	for name, input := range inputs {
+		// Expected order: greatest first, equal values by ascending index
+		indices := make([]int, len(input))
+		for i := range indices {
+			indices[i] = i
+		}
+		sort.SliceStable(indices, func(i, j int) bool { return input[indices[i]] > input[indices[j]] })
+		for _, k := range []int{0, 1, 20, 3000, 4000} {
+			top := TopK(len(input), k, func(i, j int) bool { return input[i] < input[j] })
+			want := k
+			if want > len(input) {
+				want = len(input)
+			}
+			if !equalInts(top, indices[:want]) {
+				t.Fatalf("%s: TopK(%d) = %v, want %v", name, k, top, indices[:want])
+			}
+		}
+	}
+	if top := TopK(0, 5, func(i, j int) bool { return false }); len(top) != 0 {
+		t.Fatalf("got %v from no elements", top)
+	}
+}
+
+func benchmarkSelection(b *testing.B, selection func(array []int)) {
+	for _, size := range benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			input := randomInts(rand.New(rand.NewSource(11)), size.n, size.n)
+			array := make([]int, size.n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(array, input)
+				b.StartTimer()
+				selection(array)
+			}
+		})
+	}
+}
+
+func BenchmarkNthElement(b *testing.B) {
+	benchmarkSelection(b, func(array []int) { NthElement(sort.IntSlice(array), len(array)/2) })
+}
+
+func BenchmarkPartialSort(b *testing.B) {
+	benchmarkSelection(b, func(array []int) { PartialSort(sort.IntSlice(array), 20) })
+}
+
+func BenchmarkTopK(b *testing.B) {
+	benchmarkSelection(b, func(array []int) { TopK(len(array), 20, func(i, j int) bool { return array[i] < array[j] }) })
+}
@@ -1,28 +1,299 @@
 package algorithm
 
+import (
+	"reflect"
+	"sort"
+)
+
+const insertionSortThreshold = 12
+
+// QuickSort sorts array[start, end] in place and returns array,
+// empty or single-element ranges are left untouched and out of range bounds panic
 func QuickSort(array []int, start int, end int) []int {
-	pivot := array[start]
-	i, j := start, end
-	for i < j {
-		for i < j && array[j] >= pivot {
-			j--
+	if start >= end {
+		return array
+	}
+	_ = array[start : end+1]
+	introSort(sort.IntSlice(array), start, end+1, maxDepth(end+1-start))
+	return array
+}
+
+// Sort sorts the whole array in ascending order
+func Sort(array []int) {
+	QuickSort(array, 0, len(array)-1)
+}
+
+// Slice sorts an arbitrary slice with the given less function, it is not stable
+func Slice(slice interface{}, less func(i, j int) bool) {
+	IntroSort(newLessSwap(slice, less))
+}
+
+// SliceStable sorts an arbitrary slice with the given less function,
+// keeping the original order of equal elements
+func SliceStable(slice interface{}, less func(i, j int) bool) {
+	MergeSort(newLessSwap(slice, less))
+}
+
+// IntroSort is a quick sort which falls back to heap sort when recursion goes too deep
+func IntroSort(data sort.Interface) {
+	n := data.Len()
+	introSort(data, 0, n, maxDepth(n))
+}
+
+// HeapSort sorts data in place with O(n*log(n)) worst case, it is not stable
+func HeapSort(data sort.Interface) {
+	heapSort(data, 0, data.Len())
+}
+
+// MergeSort is a stable in-place merge sort
+func MergeSort(data sort.Interface) {
+	stableSort(data, 0, data.Len())
+}
+
+type lessSwap struct {
+	length int
+	less   func(i, j int) bool
+	swap   func(i, j int)
+}
+
+func newLessSwap(slice interface{}, less func(i, j int) bool) *lessSwap {
+	return &lessSwap{reflect.ValueOf(slice).Len(), less, reflect.Swapper(slice)}
+}
+
+func (s *lessSwap) Len() int {
+	return s.length
+}
+
+func (s *lessSwap) Less(i, j int) bool {
+	return s.less(i, j)
+}
+
+func (s *lessSwap) Swap(i, j int) {
+	s.swap(i, j)
+}
+
+func maxDepth(n int) int {
+	depth := 0
+	for i := n; i > 0; i >>= 1 {
+		depth++
+	}
+	return depth * 2
+}
+
+func insertionSort(data sort.Interface, a, b int) {
+	for i := a + 1; i < b; i++ {
+		for j := i; j > a && data.Less(j, j-1); j-- {
+			data.Swap(j, j-1)
 		}
-		if i < j {
-			array[i], array[j] = array[j], array[i]
+	}
+}
+
+// siftDown restores the max-heap property of data[first+lo, first+hi)
+func siftDown(data sort.Interface, lo, hi, first int) {
+	root := lo
+	for {
+		child := 2*root + 1
+		if child >= hi {
+			return
+		}
+		if child+1 < hi && data.Less(first+child, first+child+1) {
+			child++
 		}
-		for i < j && array[i] <= pivot {
+		if !data.Less(first+root, first+child) {
+			return
+		}
+		data.Swap(first+root, first+child)
+		root = child
+	}
+}
+
+func heapSort(data sort.Interface, a, b int) {
+	first, hi := a, b-a
+	for i := (hi - 1) / 2; i >= 0; i-- {
+		siftDown(data, i, hi, first)
+	}
+	for i := hi - 1; i >= 0; i-- {
+		data.Swap(first, first+i)
+		siftDown(data, 0, i, first)
+	}
+}
+
+// partition moves data[pivot] to its final position inside [a, b) and returns it.
+// Elements equal to the pivot stop both scans, so duplicates are spread over both sides.
+func partition(data sort.Interface, a, b, pivot int) int {
+	data.Swap(a, pivot)
+	i, j := a+1, b-1
+	for {
+		for i <= j && data.Less(i, a) {
 			i++
 		}
-		if i < j {
-			array[i], array[j] = array[j], array[i]
+		for i <= j && data.Less(a, j) {
+			j--
+		}
+		if i >= j {
+			break
 		}
+		data.Swap(i, j)
+		i++
+		j--
 	}
-	array[i] = pivot
-	if start < i-1 {
-		QuickSort(array, start, i-1)
+	data.Swap(a, j)
+	return j
+}
+
+// medianOfThree returns the index of the median of data[a], data[b] and data[c]
+func medianOfThree(data sort.Interface, a, b, c int) int {
+	if data.Less(b, a) {
+		a, b = b, a
 	}
-	if i+1 < end {
-		QuickSort(array, i+1, end)
+	if data.Less(c, b) {
+		b = c
+		if data.Less(b, a) {
+			b = a
+		}
 	}
-	return array
+	return b
+}
+
+// choosePivot takes the median of three for small ranges and Tukey's ninther for large ones
+func choosePivot(data sort.Interface, a, b int) int {
+	m := int(uint(a+b) >> 1)
+	if b-a > 40 {
+		s := (b - a) / 8
+		return medianOfThree(data,
+			medianOfThree(data, a, a+s, a+2*s),
+			medianOfThree(data, m-s, m, m+s),
+			medianOfThree(data, b-1-2*s, b-1-s, b-1))
+	}
+	return medianOfThree(data, a, m, b-1)
+}
+
+func introSort(data sort.Interface, a, b, depth int) {
+	for b-a > insertionSortThreshold {
+		if depth == 0 {
+			heapSort(data, a, b)
+			return
+		}
+		depth--
+		p := partition(data, a, b, choosePivot(data, a, b))
+		// Recurse into the smaller side and loop over the larger one,
+		// so the stack never grows beyond O(log(n))
+		if p-a < b-p {
+			introSort(data, a, p, depth)
+			a = p + 1
+		} else {
+			introSort(data, p+1, b, depth)
+			b = p
+		}
+	}
+	insertionSort(data, a, b)
+}
+
+// stableSort sorts blocks with insertion sort, then merges them bottom-up with symMerge
+func stableSort(data sort.Interface, a, b int) {
+	blockSize := 20
+	i, j := a, a+blockSize
+	for j <= b {
+		insertionSort(data, i, j)
+		i = j
+		j += blockSize
+	}
+	insertionSort(data, i, b)
+	for blockSize < b-a {
+		i, j = a, a+2*blockSize
+		for j <= b {
+			symMerge(data, i, i+blockSize, j)
+			i = j
+			j += 2 * blockSize
+		}
+		if m := i + blockSize; m < b {
+			symMerge(data, i, m, b)
+		}
+		blockSize *= 2
+	}
+}
+
+// symMerge merges the sorted ranges data[a, m) and data[m, b) in place,
+// see Pok-Son Kim and Arne Kutzner, "Stable Minimum Storage Merging by Symmetric Comparisons"
+func symMerge(data sort.Interface, a, m, b int) {
+	if m-a == 1 {
+		i, j := m, b
+		for i < j {
+			h := int(uint(i+j) >> 1)
+			if data.Less(h, a) {
+				i = h + 1
+			} else {
+				j = h
+			}
+		}
+		for k := a; k < i-1; k++ {
+			data.Swap(k, k+1)
+		}
+		return
+	}
+	if b-m == 1 {
+		i, j := a, m
+		for i < j {
+			h := int(uint(i+j) >> 1)
+			if !data.Less(m, h) {
+				i = h + 1
+			} else {
+				j = h
+			}
+		}
+		for k := m; k > i; k-- {
+			data.Swap(k, k-1)
+		}
+		return
+	}
+	mid := int(uint(a+b) >> 1)
+	n := mid + m
+	var start, r int
+	if m > mid {
+		start = n - b
+		r = mid
+	} else {
+		start = a
+		r = m
+	}
+	p := n - 1
+	for start < r {
+		c := int(uint(start+r) >> 1)
+		if !data.Less(p-c, c) {
+			start = c + 1
+		} else {
+			r = c
+		}
+	}
+	end := n - start
+	if start < m && m < end {
+		rotate(data, start, m, end)
+	}
+	if a < start && start < mid {
+		symMerge(data, a, start, mid)
+	}
+	if mid < end && end < b {
+		symMerge(data, mid, end, b)
+	}
+}
+
+func swapRange(data sort.Interface, a, b, n int) {
+	for i := 0; i < n; i++ {
+		data.Swap(a+i, b+i)
+	}
+}
+
+// rotate swaps the two consecutive blocks data[a, m) and data[m, b)
+func rotate(data sort.Interface, a, m, b int) {
+	i, j := m-a, b-m
+	for i != j {
+		if i > j {
+			swapRange(data, m-i, m, j)
+			i -= j
+		} else {
+			swapRange(data, m-i, m+j-i, i)
+			j -= i
+		}
+	}
+	swapRange(data, m-i, m, i)
 }
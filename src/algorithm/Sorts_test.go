@@ -0,0 +1,178 @@
+package algorithm
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+var benchmarkSizes = []struct {
+	name string
+	n    int
+}{{"1K", 1 << 10}, {"64K", 1 << 16}, {"1M", 1 << 20}}
+
+func randomInts(random *rand.Rand, n, max int) []int {
+	array := make([]int, n)
+	for i := range array {
+		array[i] = random.Intn(max) - max/2
+	}
+	return array
+}
+
+// testInputs returns inputs of many sizes, random with few or many distinct values, sorted and reversed
+func testInputs() [][]int {
+	random := rand.New(rand.NewSource(1))
+	inputs := make([][]int, 0)
+	for n := 0; n < 600; n += 7 {
+		inputs = append(inputs, randomInts(random, n, 3), randomInts(random, n, 1000000))
+		sorted := make([]int, n)
+		reversed := make([]int, n)
+		for i := range sorted {
+			sorted[i], reversed[i] = i, n-i
+		}
+		inputs = append(inputs, sorted, reversed)
+	}
+	return inputs
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSortsMatchSortSlice(t *testing.T) {
+	sorts := map[string]func([]int){
+		"IntroSort": func(array []int) { IntroSort(sort.IntSlice(array)) },
+		"HeapSort":  func(array []int) { HeapSort(sort.IntSlice(array)) },
+		"MergeSort": func(array []int) { MergeSort(sort.IntSlice(array)) },
+		"Sort":      Sort,
+		"Slice": func(array []int) {
+			Slice(array, func(i, j int) bool { return array[i] < array[j] })
+		},
+		"SliceStable": func(array []int) {
+			SliceStable(array, func(i, j int) bool { return array[i] < array[j] })
+		},
+	}
+	for _, input := range testInputs() {
+		expected := append([]int(nil), input...)
+		sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+		for name, sortFunc := range sorts {
+			actual := append([]int(nil), input...)
+			sortFunc(actual)
+			if !equalInts(actual, expected) {
+				t.Fatalf("%s of %d elements: got %v, want %v", name, len(input), actual, expected)
+			}
+		}
+	}
+}
+
+func TestStableSortsKeepOrderOfEqualElements(t *testing.T) {
+	type record struct {
+		key, position int
+	}
+	random := rand.New(rand.NewSource(2))
+	for n := 0; n < 600; n += 7 {
+		records := make([]record, n)
+		for i := range records {
+			records[i] = record{random.Intn(5), i}
+		}
+		expected := append([]record(nil), records...)
+		sort.SliceStable(expected, func(i, j int) bool { return expected[i].key < expected[j].key })
+		SliceStable(records, func(i, j int) bool { return records[i].key < records[j].key })
+		for i := range records {
+			if records[i] != expected[i] {
+				t.Fatalf("%d records: got %v at %d, want %v", n, records[i], i, expected[i])
+			}
+		}
+	}
+}
+
+func TestQuickSortRange(t *testing.T) {
+	array := []int{5, 4, 3, 2, 1}
+	QuickSort(array, 1, 3)
+	if !equalInts(array, []int{5, 2, 3, 4, 1}) {
+		t.Fatalf("got %v", array)
+	}
+	// Empty and single-element ranges are no-ops
+	Sort(nil)
+	Sort([]int{1})
+	QuickSort([]int{}, 0, -1)
+	QuickSort(array, 2, 2)
+	QuickSort(array, 3, 2)
+}
+
+func TestQuickSortInvalidBounds(t *testing.T) {
+	for _, bounds := range [][2]int{{0, 5}, {-1, 3}, {0, 10}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("QuickSort(array, %d, %d) did not panic", bounds[0], bounds[1])
+				}
+			}()
+			QuickSort([]int{5, 4, 3, 2, 1}, bounds[0], bounds[1])
+		}()
+	}
+}
+
+func TestQuickSortSortedInput(t *testing.T) {
+	// Sorted and reversed inputs used to make the recursion O(n) deep
+	array := make([]int, 1000000)
+	for i := range array {
+		array[i] = i
+	}
+	Sort(array)
+	for i := range array {
+		array[i] = len(array) - i
+	}
+	Sort(array)
+	if !sort.IntsAreSorted(array) {
+		t.Fatal("reversed input is not sorted")
+	}
+}
+
+func benchmarkSort(b *testing.B, sortFunc func([]int)) {
+	for _, size := range benchmarkSizes {
+		b.Run(size.name, func(b *testing.B) {
+			input := randomInts(rand.New(rand.NewSource(3)), size.n, size.n)
+			array := make([]int, size.n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(array, input)
+				b.StartTimer()
+				sortFunc(array)
+			}
+		})
+	}
+}
+
+func BenchmarkSortSlice(b *testing.B) {
+	benchmarkSort(b, func(array []int) { sort.Slice(array, func(i, j int) bool { return array[i] < array[j] }) })
+}
+
+func BenchmarkIntroSort(b *testing.B) {
+	benchmarkSort(b, func(array []int) { IntroSort(sort.IntSlice(array)) })
+}
+
+func BenchmarkHeapSort(b *testing.B) {
+	benchmarkSort(b, func(array []int) { HeapSort(sort.IntSlice(array)) })
+}
+
+func BenchmarkMergeSort(b *testing.B) {
+	benchmarkSort(b, func(array []int) { MergeSort(sort.IntSlice(array)) })
+}
+
+func BenchmarkSlice(b *testing.B) {
+	benchmarkSort(b, func(array []int) { Slice(array, func(i, j int) bool { return array[i] < array[j] }) })
+}
+
+func BenchmarkSliceStable(b *testing.B) {
+	benchmarkSort(b, func(array []int) { SliceStable(array, func(i, j int) bool { return array[i] < array[j] }) })
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"github.com/go-sql-driver/mysql"
+	"go-utils/src/concurrency"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"time"
 )
 
 type VideoInfo struct {
@@ -27,13 +29,18 @@ type VideoServer struct {
 	resourcesPath string
 	database      *sql.DB
 	server        *http.Server
+	requests      *concurrency.Group
 }
 
-func (vs *VideoServer) fetchResources(w http.ResponseWriter, r *http.Request) {
+// fetchResourcesTTL is how long the resources listing is reused before the table is scanned again
+const fetchResourcesTTL = 5 * time.Second
+
+func (vs *VideoServer) queryResources() (interface{}, error) {
 	rows, e := vs.database.Query("select * from tb_porn_index")
 	if e != nil {
-		log.Fatalln(e)
+		return nil, e
 	}
+	defer rows.Close()
 
 	resources := make([]VideoInfo, 0)
 	for rows.Next() {
@@ -47,10 +54,13 @@ func (vs *VideoServer) fetchResources(w http.ResponseWriter, r *http.Request) {
 			&result.VideoPath,
 		)
 		if e != nil {
-			log.Fatalln(e)
+			return nil, e
 		}
 		resources = append(resources, result)
 	}
+	if e := rows.Err(); e != nil {
+		return nil, e
+	}
 
 	response := make(map[string][]VideoInfo)
 	for i := range resources {
@@ -60,14 +70,18 @@ func (vs *VideoServer) fetchResources(w http.ResponseWriter, r *http.Request) {
 
 		response[resources[i].EnName] = append(response[resources[i].EnName], resources[i])
 	}
+	return json.Marshal(response)
+}
 
-	bytes, e := json.Marshal(response)
+func (vs *VideoServer) fetchResources(w http.ResponseWriter, r *http.Request) {
+	// Concurrent requests share one scan of the table
+	bytes, e, _ := vs.requests.Do("fetchResources", vs.queryResources)
 	if e != nil {
 		log.Fatalln(e)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if i, e := w.Write(bytes); e != nil {
+	if i, e := w.Write(bytes.([]byte)); e != nil {
 		log.Fatalln(e)
 	} else {
 		log.Println("Response Length ", i)
@@ -100,6 +114,7 @@ func NewVideoServer(dataSource, resourcesPath string) (*VideoServer, error) {
 	vs := new(VideoServer)
 	vs.dataSource = dataSource
 	vs.resourcesPath = resourcesPath
+	vs.requests = concurrency.NewGroup(fetchResourcesTTL)
 
 	config := mysql.NewConfig()
 	config.Net = "tcp"
@@ -218,5 +233,6 @@ func (vs *VideoServer) Index() error {
 	if e = statement.Close(); e != nil {
 		return e
 	}
+	vs.requests.Forget("fetchResources")
 	return nil
 }
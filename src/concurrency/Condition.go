@@ -0,0 +1,36 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// waitCondition waits on condition until ready holds or ctx is done, condition.L must be held.
+// A helper goroutine turns the cancellation of ctx into a broadcast, since sync.Cond knows nothing of contexts.
+func waitCondition(ctx context.Context, condition *sync.Cond, ready func() bool) error {
+	if ctx.Done() == nil {
+		for !ready() {
+			condition.Wait()
+		}
+		return nil
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			condition.L.Lock()
+			condition.Broadcast()
+			condition.L.Unlock()
+		case <-stop:
+		}
+	}()
+	for !ready() {
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+		condition.Wait()
+	}
+	return nil
+}
@@ -1,21 +1,40 @@
 package concurrency
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
+// CountDownLatch releases every waiter once CountDown has been called value times.
+// Like sync.WaitGroup, counting down a released latch is a programming error and panics.
 type CountDownLatch struct {
 	countValue int
 	condition  *sync.Cond
+	done       chan struct{}
 }
 
 func (latch *CountDownLatch) CountDown() {
 	latch.condition.L.Lock()
+	if latch.countValue == 0 {
+		latch.condition.L.Unlock()
+		panic("concurrency: CountDown called on a released CountDownLatch")
+	}
 	latch.countValue--
 	if latch.countValue == 0 {
-		latch.condition.Signal()
+		close(latch.done)
+		latch.condition.Broadcast()
 	}
 	latch.condition.L.Unlock()
 }
 
+// Count returns how many CountDown calls are still needed
+func (latch *CountDownLatch) Count() int {
+	latch.condition.L.Lock()
+	defer latch.condition.L.Unlock()
+	return latch.countValue
+}
+
 func (latch *CountDownLatch) Await() {
 	latch.condition.L.Lock()
 	for latch.countValue != 0 {
@@ -24,6 +43,50 @@ func (latch *CountDownLatch) Await() {
 	latch.condition.L.Unlock()
 }
 
+// Done is closed once the latch is released
+func (latch *CountDownLatch) Done() <-chan struct{} {
+	return latch.done
+}
+
+// AwaitContext waits for the latch, or returns ctx.Err() when ctx is done first
+func (latch *CountDownLatch) AwaitContext(ctx context.Context) error {
+	select {
+	case <-latch.done:
+		return nil
+	default:
+	}
+	select {
+	case <-latch.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AwaitTimeout waits at most timeout and reports whether the latch was released
+func (latch *CountDownLatch) AwaitTimeout(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-latch.done:
+		return true
+	case <-timer.C:
+		select {
+		case <-latch.done:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
 func NewCountDownLatch(value int) *CountDownLatch {
-	return &CountDownLatch{value, sync.NewCond(new(sync.Mutex))}
+	if value < 0 {
+		panic("concurrency: negative CountDownLatch count")
+	}
+	latch := &CountDownLatch{value, sync.NewCond(new(sync.Mutex)), make(chan struct{})}
+	if value == 0 {
+		close(latch.done)
+	}
+	return latch
 }
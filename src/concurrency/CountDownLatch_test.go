@@ -0,0 +1,126 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCountDownLatchReleasesEveryWaiter(t *testing.T) {
+	const waiters = 90
+	const count = 5
+	latch := NewCountDownLatch(count)
+	var released int64
+	var group sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			switch i % 3 {
+			case 0:
+				latch.Await()
+			case 1:
+				if e := latch.AwaitContext(context.Background()); e != nil {
+					t.Errorf("AwaitContext returned %v", e)
+					return
+				}
+			case 2:
+				if !latch.AwaitTimeout(time.Minute) {
+					t.Error("AwaitTimeout timed out")
+					return
+				}
+			}
+			if latch.Count() != 0 {
+				t.Errorf("waiter released with count %d", latch.Count())
+			}
+			atomic.AddInt64(&released, 1)
+		}(i)
+	}
+
+	for i := count; i > 0; i-- {
+		time.Sleep(time.Millisecond)
+		if n := atomic.LoadInt64(&released); n != 0 {
+			t.Fatalf("%d waiters released with count %d", n, i)
+		}
+		if latch.Count() != i {
+			t.Fatalf("Count is %d, want %d", latch.Count(), i)
+		}
+		latch.CountDown()
+	}
+	group.Wait()
+	if released != waiters {
+		t.Fatalf("%d of %d waiters released", released, waiters)
+	}
+	select {
+	case <-latch.Done():
+	default:
+		t.Fatal("Done is not closed")
+	}
+}
+
+func TestCountDownLatchConcurrentCountDown(t *testing.T) {
+	const count = 200
+	latch := NewCountDownLatch(count)
+	for i := 0; i < count; i++ {
+		go latch.CountDown()
+	}
+	if !latch.AwaitTimeout(10 * time.Second) {
+		t.Fatalf("latch not released, count is %d", latch.Count())
+	}
+}
+
+func TestCountDownLatchWaitersGiveUp(t *testing.T) {
+	latch := NewCountDownLatch(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	var group sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		group.Add(2)
+		go func() {
+			defer group.Done()
+			if e := latch.AwaitContext(ctx); e != context.DeadlineExceeded {
+				t.Errorf("AwaitContext returned %v", e)
+			}
+		}()
+		go func() {
+			defer group.Done()
+			if latch.AwaitTimeout(5 * time.Millisecond) {
+				t.Error("AwaitTimeout reported a release")
+			}
+		}()
+	}
+	group.Wait()
+	if latch.Count() != 1 {
+		t.Fatalf("Count is %d", latch.Count())
+	}
+}
+
+func TestCountDownLatchZero(t *testing.T) {
+	latch := NewCountDownLatch(0)
+	latch.Await()
+	if !latch.AwaitTimeout(0) {
+		t.Fatal("a latch of zero is released from the start")
+	}
+}
+
+func TestCountDownLatchBelowZeroPanics(t *testing.T) {
+	latch := NewCountDownLatch(1)
+	latch.CountDown()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("CountDown on a released latch did not panic")
+		}
+	}()
+	latch.CountDown()
+}
+
+func TestNewCountDownLatchNegativePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewCountDownLatch(-1) did not panic")
+		}
+	}()
+	NewCountDownLatch(-1)
+}
@@ -0,0 +1,171 @@
+package concurrency
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCron = errors.New("concurrency: invalid cron expression")
+
+// CronSchedule is a classic five field cron expression: minute, hour, day of month, month and day of week.
+// Fields accept *, lists, ranges, steps and English names, like "*/15 9-18 * * MON-FRI".
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly are understood as well.
+type CronSchedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// When both day fields are restricted a day matching either of them is enough
+	anyDay bool
+}
+
+type cronField struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronMinute     = cronField{0, 59, nil}
+	cronHour       = cronField{0, 23, nil}
+	cronDayOfMonth = cronField{1, 31, nil}
+	cronMonth      = cronField{1, 12, map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// 7 is accepted for Sunday and folded onto 0
+	cronDayOfWeek = cronField{0, 7, map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+func ParseCron(expression string) (*CronSchedule, error) {
+	expression = strings.TrimSpace(expression)
+	if descriptor, exist := cronDescriptors[strings.ToLower(expression)]; exist {
+		expression = descriptor
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, ErrInvalidCron
+	}
+
+	schedule := new(CronSchedule)
+	targets := []*uint64{&schedule.minute, &schedule.hour, &schedule.dayOfMonth, &schedule.month, &schedule.dayOfWeek}
+	specs := []cronField{cronMinute, cronHour, cronDayOfMonth, cronMonth, cronDayOfWeek}
+	for i := range fields {
+		bits, e := specs[i].parse(fields[i])
+		if e != nil {
+			return nil, e
+		}
+		*targets[i] = bits
+	}
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+	schedule.anyDay = !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")
+	return schedule, nil
+}
+
+func (field cronField) value(s string) (int, error) {
+	if v, exist := field.names[strings.ToUpper(s)]; exist {
+		return v, nil
+	}
+	v, e := strconv.Atoi(s)
+	if e != nil || v < field.min || v > field.max {
+		return 0, ErrInvalidCron
+	}
+	return v, nil
+}
+
+func (field cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			v, e := strconv.Atoi(part[i+1:])
+			if e != nil || v < 1 {
+				return 0, ErrInvalidCron
+			}
+			step = v
+			part = part[:i]
+		}
+
+		var lo, hi int
+		if part == "*" {
+			lo, hi = field.min, field.max
+		} else if i := strings.Index(part, "-"); i >= 0 {
+			var e error
+			if lo, e = field.value(part[:i]); e != nil {
+				return 0, e
+			}
+			if hi, e = field.value(part[i+1:]); e != nil {
+				return 0, e
+			}
+		} else {
+			v, e := field.value(part)
+			if e != nil {
+				return 0, e
+			}
+			// "5/15" means from 5 to the end in steps of 15
+			lo, hi = v, v
+			if step > 1 {
+				hi = field.max
+			}
+		}
+		if lo > hi {
+			return 0, ErrInvalidCron
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	dom := schedule.dayOfMonth&(1<<uint(t.Day())) != 0
+	dow := schedule.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if schedule.anyDay {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Next returns the first matching minute strictly after t, or the zero time when none exists within five years
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	location := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, location).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+		if !schedule.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
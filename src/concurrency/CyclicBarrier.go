@@ -0,0 +1,117 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrBrokenBarrier = errors.New("concurrency: broken barrier")
+
+type barrierGeneration struct {
+	broken bool
+}
+
+// CyclicBarrier lets a fixed number of parties wait for each other, then resets itself for the next round.
+// The action runs in the last arriving goroutine before the others are released, while the barrier is locked.
+type CyclicBarrier struct {
+	parties    int
+	arrived    int
+	action     func()
+	generation *barrierGeneration
+	condition  *sync.Cond
+}
+
+func NewCyclicBarrier(parties int, action func()) *CyclicBarrier {
+	if parties < 1 {
+		panic("concurrency: CyclicBarrier needs at least one party")
+	}
+	return &CyclicBarrier{
+		parties:    parties,
+		action:     action,
+		generation: new(barrierGeneration),
+		condition:  sync.NewCond(new(sync.Mutex)),
+	}
+}
+
+// Await returns the arrival index, parties-1 for the first goroutine and 0 for the last one
+func (barrier *CyclicBarrier) Await() (int, error) {
+	return barrier.AwaitContext(context.Background())
+}
+
+// AwaitContext breaks the barrier for every party when ctx is done before the round completes
+func (barrier *CyclicBarrier) AwaitContext(ctx context.Context) (int, error) {
+	barrier.condition.L.Lock()
+	defer barrier.condition.L.Unlock()
+
+	generation := barrier.generation
+	if generation.broken {
+		return -1, ErrBrokenBarrier
+	}
+	barrier.arrived++
+	index := barrier.parties - barrier.arrived
+	if index == 0 {
+		if barrier.action != nil {
+			completed := false
+			defer func() {
+				if !completed {
+					barrier.breakBarrier()
+				}
+			}()
+			barrier.action()
+			completed = true
+		}
+		barrier.nextGeneration()
+		return 0, nil
+	}
+
+	e := waitCondition(ctx, barrier.condition, func() bool {
+		return generation != barrier.generation || generation.broken
+	})
+	if e != nil {
+		if generation == barrier.generation && !generation.broken {
+			barrier.breakBarrier()
+		}
+		return index, e
+	}
+	if generation.broken {
+		return index, ErrBrokenBarrier
+	}
+	return index, nil
+}
+
+// Reset breaks the current round, waiting parties get ErrBrokenBarrier
+func (barrier *CyclicBarrier) Reset() {
+	barrier.condition.L.Lock()
+	barrier.breakBarrier()
+	barrier.nextGeneration()
+	barrier.condition.L.Unlock()
+}
+
+func (barrier *CyclicBarrier) IsBroken() bool {
+	barrier.condition.L.Lock()
+	defer barrier.condition.L.Unlock()
+	return barrier.generation.broken
+}
+
+func (barrier *CyclicBarrier) NumberWaiting() int {
+	barrier.condition.L.Lock()
+	defer barrier.condition.L.Unlock()
+	return barrier.arrived
+}
+
+func (barrier *CyclicBarrier) Parties() int {
+	return barrier.parties
+}
+
+func (barrier *CyclicBarrier) nextGeneration() {
+	barrier.arrived = 0
+	barrier.generation = new(barrierGeneration)
+	barrier.condition.Broadcast()
+}
+
+func (barrier *CyclicBarrier) breakBarrier() {
+	barrier.generation.broken = true
+	barrier.arrived = 0
+	barrier.condition.Broadcast()
+}
@@ -0,0 +1,88 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// ErrGroup runs related tasks, the first one failing cancels the context of all of them.
+// A panicking task fails the group with a *PanicError.
+type ErrGroup struct {
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	errOnce sync.Once
+	e       error
+}
+
+// NewErrGroup returns the group and the context its tasks should watch, cancelled once a task fails or Wait returns
+func NewErrGroup(ctx context.Context) (*ErrGroup, context.Context) {
+	group := new(ErrGroup)
+	group.ctx, group.cancel = context.WithCancel(ctx)
+	return group, group.ctx
+}
+
+func (group *ErrGroup) fail(e error) {
+	group.errOnce.Do(func() {
+		group.e = e
+		group.cancel()
+	})
+}
+
+func (group *ErrGroup) run(task func() error) {
+	defer group.wg.Done()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			group.fail(newPanicError(recovered))
+		}
+	}()
+	if e := task(); e != nil {
+		group.fail(e)
+	}
+}
+
+// Go runs task in a new goroutine, meant for tasks which spend their time waiting
+func (group *ErrGroup) Go(task func() error) {
+	group.wg.Add(1)
+	go group.run(task)
+}
+
+// GoPool runs task on pool, waiting for room in its queue until the group is cancelled.
+// A task which could not be submitted fails the group and its error is returned,
+// one discarded by the rejection policy fails it with ErrTaskDiscarded
+// and one dropped by ShutdownNow with ErrPoolClosed.
+func (group *ErrGroup) GoPool(pool *RoutinesPool, task func() error) error {
+	return group.goPool(pool, task, nil)
+}
+
+// goPool is GoPool calling discarded, when given, for a task dropped without running
+// so that the caller can release what it holds for the task
+func (group *ErrGroup) goPool(pool *RoutinesPool, task func() error, discarded func()) error {
+	group.wg.Add(1)
+	failed := func(e error) {
+		group.fail(e)
+		group.wg.Done()
+	}
+	e := pool.submit(group.ctx, &poolTask{
+		run: func() {
+			group.run(task)
+		},
+		discarded: func(e error) {
+			if discarded != nil {
+				discarded()
+			}
+			failed(e)
+		},
+	}, submitContext)
+	if e != nil {
+		failed(e)
+	}
+	return e
+}
+
+// Wait returns the first error once every task has returned
+func (group *ErrGroup) Wait() error {
+	group.wg.Wait()
+	group.cancel()
+	return group.e
+}
@@ -0,0 +1,142 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNoTasks = errors.New("concurrency: no task to invoke")
+
+type Callable func() (interface{}, error)
+
+// Future holds the result of a Callable running on a RoutinesPool
+type Future struct {
+	value interface{}
+	e     error
+	done  chan struct{}
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (future *Future) complete(value interface{}, e error) {
+	future.value = value
+	future.e = e
+	close(future.done)
+}
+
+// run completes the future with task's result. A panic completes it with a *PanicError,
+// then keeps unwinding so the worker reports it as well.
+func (future *Future) run(task Callable) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			e := newPanicError(recovered)
+			future.complete(nil, e)
+			panic(e)
+		}
+	}()
+	future.complete(task())
+}
+
+// Done is closed once the result is available
+func (future *Future) Done() <-chan struct{} {
+	return future.done
+}
+
+func (future *Future) IsDone() bool {
+	select {
+	case <-future.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Get blocks until the task returns
+func (future *Future) Get() (interface{}, error) {
+	<-future.done
+	return future.value, future.e
+}
+
+// GetContext stops waiting when ctx is done, the task itself keeps running
+func (future *Future) GetContext(ctx context.Context) (interface{}, error) {
+	select {
+	case <-future.done:
+		return future.value, future.e
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SubmitWithResult submits task like Submit. The future completes with the submission error
+// when the pool refuses the task, with ErrTaskDiscarded when a rejection policy drops it
+// and with ErrPoolClosed when ShutdownNow drops it.
+func (pool *RoutinesPool) SubmitWithResult(task Callable) *Future {
+	future := newFuture()
+	e := pool.submit(nil, &poolTask{
+		run: func() {
+			future.run(task)
+		},
+		discarded: func(e error) {
+			future.complete(nil, e)
+		},
+	}, submitWait)
+	if e != nil {
+		future.complete(nil, e)
+	}
+	return future
+}
+
+// InvokeAll runs every task on the pool and waits until all of them returned.
+// Calling it from a task of the same pool may deadlock once every worker waits.
+func (pool *RoutinesPool) InvokeAll(tasks []Callable) []*Future {
+	futures := make([]*Future, len(tasks))
+	for i := range tasks {
+		futures[i] = pool.SubmitWithResult(tasks[i])
+	}
+	for i := range futures {
+		<-futures[i].done
+	}
+	return futures
+}
+
+// InvokeAny runs every task on the pool and returns the first successful result,
+// or the last error when all of them failed. Slower tasks keep running in the background.
+func (pool *RoutinesPool) InvokeAny(tasks []Callable) (interface{}, error) {
+	if len(tasks) == 0 {
+		return nil, ErrNoTasks
+	}
+	// Buffered so that tasks finishing after the winner never block their worker
+	results := make(chan *Future, len(tasks))
+	for i := range tasks {
+		task := tasks[i]
+		failed := func(e error) {
+			future := newFuture()
+			future.complete(nil, e)
+			results <- future
+		}
+		e := pool.submit(nil, &poolTask{
+			run: func() {
+				future := newFuture()
+				defer func() {
+					results <- future
+				}()
+				future.run(task)
+			},
+			discarded: failed,
+		}, submitWait)
+		if e != nil {
+			failed(e)
+		}
+	}
+	var e error
+	for range tasks {
+		future := <-results
+		if future.e == nil {
+			return future.value, nil
+		}
+		e = future.e
+	}
+	return nil, e
+}
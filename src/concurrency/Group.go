@@ -0,0 +1,125 @@
+package concurrency
+
+import (
+	"sync"
+	"time"
+)
+
+// Result is what DoChan delivers
+type Result struct {
+	Value  interface{}
+	Err    error
+	Shared bool
+}
+
+type flight struct {
+	done    chan struct{}
+	value   interface{}
+	e       error
+	dups    int
+	expires time.Time
+}
+
+// Group coalesces calls by key: while a call is running, callers asking for the same key wait for
+// its result instead of starting their own. With a positive ttl successful results are also kept that long.
+// The zero value is a Group without caching.
+type Group struct {
+	mutex   sync.Mutex
+	flights map[string]*flight
+	ttl     time.Duration
+}
+
+func NewGroup(ttl time.Duration) *Group {
+	return &Group{flights: make(map[string]*flight), ttl: ttl}
+}
+
+// join returns the flight of key and whether the caller has to run it
+func (group *Group) join(key string) (*flight, bool) {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	if group.flights == nil {
+		group.flights = make(map[string]*flight)
+	}
+	if f, exist := group.flights[key]; exist {
+		select {
+		case <-f.done:
+			if time.Now().Before(f.expires) {
+				return f, false
+			}
+		default:
+			f.dups++
+			return f, false
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	group.flights[key] = f
+	return f, true
+}
+
+// execute runs fn for f, a panic is handed to every caller as a *PanicError
+func (group *Group) execute(key string, f *flight, fn func() (interface{}, error)) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			f.value, f.e = nil, newPanicError(recovered)
+		}
+		group.mutex.Lock()
+		if group.ttl > 0 && f.e == nil {
+			f.expires = time.Now().Add(group.ttl)
+			time.AfterFunc(group.ttl, func() {
+				group.mutex.Lock()
+				group.forget(key, f)
+				group.mutex.Unlock()
+			})
+		} else {
+			group.forget(key, f)
+		}
+		close(f.done)
+		group.mutex.Unlock()
+	}()
+	f.value, f.e = fn()
+}
+
+// forget removes f unless key has been taken over since, it must be called with mutex held
+func (group *Group) forget(key string, f *flight) {
+	if group.flights[key] == f {
+		delete(group.flights, key)
+	}
+}
+
+// Do runs fn unless a call for key is running or cached, shared tells whether the result went to several callers
+func (group *Group) Do(key string, fn func() (interface{}, error)) (value interface{}, e error, shared bool) {
+	f, leader := group.join(key)
+	if leader {
+		group.execute(key, f, fn)
+	}
+	<-f.done
+	group.mutex.Lock()
+	shared = !leader || f.dups > 0
+	group.mutex.Unlock()
+	return f.value, f.e, shared
+}
+
+// DoChan is Do without blocking, the channel receives exactly one Result
+func (group *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	result := make(chan Result, 1)
+	f, leader := group.join(key)
+	go func() {
+		if leader {
+			group.execute(key, f, fn)
+		}
+		<-f.done
+		group.mutex.Lock()
+		shared := !leader || f.dups > 0
+		group.mutex.Unlock()
+		result <- Result{f.value, f.e, shared}
+	}()
+	return result
+}
+
+// Forget drops the running or cached call of key, the next caller starts a new one.
+// Callers already waiting still get the result of the old call.
+func (group *Group) Forget(key string) {
+	group.mutex.Lock()
+	delete(group.flights, key)
+	group.mutex.Unlock()
+}
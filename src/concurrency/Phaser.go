@@ -0,0 +1,163 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// Phaser is a reusable barrier whose number of parties may change between phases.
+// A phase advances once every registered party has arrived, then onAdvance decides
+// whether the phaser terminates. Methods return -1 for the phase once terminated.
+type Phaser struct {
+	phase      int
+	parties    int
+	arrived    int
+	terminated bool
+	onAdvance  func(phase, parties int) bool
+	condition  *sync.Cond
+}
+
+func NewPhaser(parties int) *Phaser {
+	if parties < 0 {
+		panic("concurrency: negative Phaser parties")
+	}
+	return &Phaser{
+		parties: parties,
+		onAdvance: func(phase, parties int) bool {
+			return parties == 0
+		},
+		condition: sync.NewCond(new(sync.Mutex)),
+	}
+}
+
+// SetOnAdvance replaces the hook called before every advance, returning true terminates the phaser
+func (phaser *Phaser) SetOnAdvance(onAdvance func(phase, parties int) bool) {
+	phaser.condition.L.Lock()
+	phaser.onAdvance = onAdvance
+	phaser.condition.L.Unlock()
+}
+
+// Register adds a party and returns the phase it joins
+func (phaser *Phaser) Register() int {
+	return phaser.BulkRegister(1)
+}
+
+func (phaser *Phaser) BulkRegister(parties int) int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	if phaser.terminated {
+		return -1
+	}
+	phaser.parties += parties
+	return phaser.phase
+}
+
+// Arrive marks one party arrived without waiting and returns the arrival phase
+func (phaser *Phaser) Arrive() int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	return phaser.arrive(false)
+}
+
+// ArriveAndDeregister arrives and leaves, the next phases no longer wait for this party
+func (phaser *Phaser) ArriveAndDeregister() int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	return phaser.arrive(true)
+}
+
+// ArriveAndAwaitAdvance arrives and waits for the others, it returns the arrival phase
+func (phaser *Phaser) ArriveAndAwaitAdvance() int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	phase := phaser.arrive(false)
+	if phase < 0 {
+		return phase
+	}
+	for phaser.phase == phase && !phaser.terminated {
+		phaser.condition.Wait()
+	}
+	if phaser.terminated {
+		return -1
+	}
+	return phase
+}
+
+// AwaitAdvance waits until the phaser leaves phase and returns the current phase
+func (phaser *Phaser) AwaitAdvance(phase int) int {
+	current, _ := phaser.AwaitAdvanceContext(context.Background(), phase)
+	return current
+}
+
+func (phaser *Phaser) AwaitAdvanceContext(ctx context.Context, phase int) (int, error) {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	e := waitCondition(ctx, phaser.condition, func() bool {
+		return phaser.phase != phase || phaser.terminated
+	})
+	return phaser.currentPhase(), e
+}
+
+func (phaser *Phaser) Phase() int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	return phaser.currentPhase()
+}
+
+func (phaser *Phaser) RegisteredParties() int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	return phaser.parties
+}
+
+func (phaser *Phaser) ArrivedParties() int {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	return phaser.arrived
+}
+
+func (phaser *Phaser) IsTerminated() bool {
+	phaser.condition.L.Lock()
+	defer phaser.condition.L.Unlock()
+	return phaser.terminated
+}
+
+// ForceTermination releases every waiter, later arrivals return -1
+func (phaser *Phaser) ForceTermination() {
+	phaser.condition.L.Lock()
+	phaser.terminated = true
+	phaser.condition.Broadcast()
+	phaser.condition.L.Unlock()
+}
+
+func (phaser *Phaser) currentPhase() int {
+	if phaser.terminated {
+		return -1
+	}
+	return phaser.phase
+}
+
+func (phaser *Phaser) arrive(deregister bool) int {
+	if phaser.terminated {
+		return -1
+	}
+	// Callers unlock in a deferred call, which still runs while panicking
+	if phaser.arrived >= phaser.parties {
+		panic("concurrency: Phaser arrival by an unregistered party")
+	}
+	phase := phaser.phase
+	if deregister {
+		phaser.parties--
+	} else {
+		phaser.arrived++
+	}
+	if phaser.arrived == phaser.parties {
+		if phaser.onAdvance(phase, phaser.parties) {
+			phaser.terminated = true
+		}
+		phaser.phase++
+		phaser.arrived = 0
+		phaser.condition.Broadcast()
+	}
+	return phase
+}
@@ -0,0 +1,235 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrSkipItem returned by a StageFunc drops the item without failing the pipeline
+var ErrSkipItem = errors.New("concurrency: skip item")
+
+// SourceFunc feeds the pipeline, emit fails once the pipeline has been cancelled
+type SourceFunc func(ctx context.Context, emit func(item interface{}) error) error
+
+// StageFunc turns one item into one result
+type StageFunc func(ctx context.Context, item interface{}) (interface{}, error)
+
+// FlatStageFunc turns one item into any number of results, like the segments of a playlist
+type FlatStageFunc func(ctx context.Context, item interface{}, emit func(result interface{})) error
+
+// SinkFunc consumes the results of the last stage, it is called from a single goroutine
+type SinkFunc func(ctx context.Context, item interface{}) error
+
+// StageError is returned by Run when a stage fails
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return "stage " + e.Stage + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+type pipelineStage struct {
+	name    string
+	workers int
+	buffer  int
+	run     FlatStageFunc
+}
+
+// Pipeline chains stages over channels. Every stage runs its items on the pool, up to its own number
+// of workers at once, and hands the results to the next stage through a buffer of bounded size.
+// Results of a stage with several workers come out in completion order.
+//
+// The first error, from the source, a stage or the sink, cancels the whole pipeline.
+type Pipeline struct {
+	pool   *RoutinesPool
+	stages []pipelineStage
+}
+
+func NewPipeline(pool *RoutinesPool) *Pipeline {
+	return &Pipeline{pool: pool}
+}
+
+// Stage appends a one to one stage
+func (pipeline *Pipeline) Stage(name string, workers, buffer int, stage StageFunc) *Pipeline {
+	return pipeline.FlatStage(name, workers, buffer, func(ctx context.Context, item interface{}, emit func(interface{})) error {
+		result, e := stage(ctx, item)
+		if e == ErrSkipItem {
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+		emit(result)
+		return nil
+	})
+}
+
+// FlatStage appends a one to many stage, the results of an item are passed on once it is done
+func (pipeline *Pipeline) FlatStage(name string, workers, buffer int, stage FlatStageFunc) *Pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+	pipeline.stages = append(pipeline.stages, pipelineStage{name, workers, buffer, stage})
+	return pipeline
+}
+
+// Run pushes every item of source through the stages into sink, a nil sink drops the results.
+// It returns once every goroutine and task of the pipeline has finished.
+func (pipeline *Pipeline) Run(ctx context.Context, source SourceFunc, sink SinkFunc) error {
+	group, ctx := NewErrGroup(ctx)
+	out := make(chan interface{})
+	group.Go(func() error {
+		defer close(out)
+		return source(ctx, func(item interface{}) error {
+			select {
+			case out <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	})
+
+	var in <-chan interface{} = out
+	for i := range pipeline.stages {
+		in = pipeline.start(ctx, group, pipeline.stages[i], in)
+	}
+
+	group.Go(func() error {
+		for item := range in {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+			if sink == nil {
+				continue
+			}
+			if e := sink(ctx, item); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	return group.Wait()
+}
+
+// start runs stage between in and the returned channel. A dispatcher submits every item to the pool
+// and a forwarder passes the results on, so pool workers never wait for a slow next stage.
+func (pipeline *Pipeline) start(ctx context.Context, group *ErrGroup, stage pipelineStage, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{}, stage.buffer)
+	// Every task sends exactly one batch and at most workers tasks are in flight, so sending never blocks
+	results := make(chan []interface{}, stage.workers)
+	semaphore := NewSemaphore(stage.workers)
+	var running sync.WaitGroup
+
+	group.Go(func() error {
+		defer func() {
+			running.Wait()
+			close(results)
+		}()
+		for item := range in {
+			if e := semaphore.AcquireContext(ctx, 1); e != nil {
+				return e
+			}
+			item := item
+			running.Add(1)
+			e := group.goPool(pipeline.pool, func() error {
+				var batch []interface{}
+				completed := false
+				defer func() {
+					if !completed {
+						batch = nil
+					}
+					results <- batch
+					running.Done()
+				}()
+				if e := stage.run(ctx, item, func(result interface{}) {
+					batch = append(batch, result)
+				}); e != nil {
+					return &StageError{stage.name, e}
+				}
+				completed = true
+				return nil
+			}, func() {
+				// A task dropped by the pool still owes its batch, which frees its slot
+				results <- nil
+				running.Done()
+			})
+			if e != nil {
+				running.Done()
+				semaphore.Release(1)
+				return e
+			}
+		}
+		return nil
+	})
+
+	group.Go(func() error {
+		defer close(out)
+		for batch := range results {
+			for i := 0; i < len(batch) && ctx.Err() == nil; i++ {
+				select {
+				case out <- batch[i]:
+				case <-ctx.Done():
+				}
+			}
+			semaphore.Release(1)
+		}
+		return nil
+	})
+	return out
+}
+
+// FanIn merges inputs into one channel, closed once all of them are. Once ctx is done it stops reading.
+func FanIn(ctx context.Context, inputs ...<-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	var wg sync.WaitGroup
+	wg.Add(len(inputs))
+	for _, input := range inputs {
+		go func(input <-chan interface{}) {
+			defer wg.Done()
+			for item := range input {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(input)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FanOut spreads the items of input over n channels, each item goes to whichever consumer is ready first.
+// The channels are closed once input is, or once ctx is done.
+func FanOut(ctx context.Context, input <-chan interface{}, n int) []<-chan interface{} {
+	outputs := make([]<-chan interface{}, n)
+	for i := range outputs {
+		out := make(chan interface{})
+		outputs[i] = out
+		go func() {
+			defer close(out)
+			for item := range input {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return outputs
+}
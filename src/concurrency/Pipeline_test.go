@@ -0,0 +1,109 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// numbers emits 1 to n
+func numbers(n int) SourceFunc {
+	return func(ctx context.Context, emit func(item interface{}) error) error {
+		for i := 1; i <= n; i++ {
+			if e := emit(i); e != nil {
+				return e
+			}
+		}
+		return nil
+	}
+}
+
+// runWithin fails the test when Run has not returned after timeout
+func runWithin(t *testing.T, pipeline *Pipeline, source SourceFunc, sink SinkFunc, timeout time.Duration) error {
+	t.Helper()
+	result := make(chan error, 1)
+	go func() {
+		result <- pipeline.Run(context.Background(), source, sink)
+	}()
+	select {
+	case e := <-result:
+		return e
+	case <-time.After(timeout):
+		t.Fatal("Run deadlocked")
+		return nil
+	}
+}
+
+func TestPipelineRunsStages(t *testing.T) {
+	pool := NewRoutinesPool(4)
+	defer pool.Close()
+	pipeline := NewPipeline(pool).
+		Stage("odd", 3, 1, func(ctx context.Context, item interface{}) (interface{}, error) {
+			if item.(int)%2 == 0 {
+				return nil, ErrSkipItem
+			}
+			return item, nil
+		}).
+		FlatStage("twice", 2, 0, func(ctx context.Context, item interface{}, emit func(interface{})) error {
+			emit(item)
+			emit(item)
+			return nil
+		})
+	sum, count := 0, 0
+	e := runWithin(t, pipeline, numbers(10), func(ctx context.Context, item interface{}) error {
+		sum += item.(int)
+		count++
+		return nil
+	}, 5*time.Second)
+	if e != nil || sum != 50 || count != 10 {
+		t.Fatalf("got sum %d of %d results and %v, want 50 of 10", sum, count, e)
+	}
+}
+
+func TestPipelineStageError(t *testing.T) {
+	pool := NewRoutinesPool(2)
+	defer pool.Close()
+	failure := errors.New("failure")
+	pipeline := NewPipeline(pool).Stage("check", 2, 0, func(ctx context.Context, item interface{}) (interface{}, error) {
+		if item.(int) == 3 {
+			return nil, failure
+		}
+		return item, nil
+	})
+	e := runWithin(t, pipeline, numbers(100), nil, 5*time.Second)
+	if stageError, ok := e.(*StageError); !ok || stageError.Stage != "check" || stageError.Err != failure {
+		t.Fatalf("got %v, want the failure of stage check", e)
+	}
+}
+
+func TestPipelineDiscardedTask(t *testing.T) {
+	pool := NewDynamicRoutinesPool(1, 1, 1, 0)
+	defer pool.Close()
+	pool.SetRejectionPolicy(DiscardOldestPolicy)
+	// Tasks hold the only worker until the pipeline fails
+	pipeline := NewPipeline(pool).Stage("wait", 2, 0, func(ctx context.Context, item interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	result := make(chan error, 1)
+	go func() {
+		result <- pipeline.Run(context.Background(), numbers(2), nil)
+	}()
+	eventually(t, 5*time.Second, func() bool {
+		stats := pool.Stats()
+		return stats.Active == 1 && stats.Queued == 1
+	}, "the second item is not queued")
+	// Another submitter of the pool discards the queued stage task
+	if e := pool.Submit(func() {}); e != nil {
+		t.Fatal(e)
+	}
+	select {
+	case e := <-result:
+		if e != ErrTaskDiscarded {
+			t.Fatalf("got %v, want ErrTaskDiscarded", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run deadlocked")
+	}
+}
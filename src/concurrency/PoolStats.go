@@ -0,0 +1,244 @@
+package concurrency
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBounds are the upper bounds of the buckets used for wait and run latencies,
+// every pool copies them when it is created
+var DefaultLatencyBounds = []time.Duration{
+	100 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 5 * time.Second, 10 * time.Second, time.Minute,
+}
+
+// LatencyHistogram counts durations per bucket, Counts[i] holds the durations not greater than Bounds[i]
+// which did not fit a previous bucket, and the last element of Counts holds everything above the last bound
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func newLatencyHistogram() LatencyHistogram {
+	bounds := append([]time.Duration(nil), DefaultLatencyBounds...)
+	return LatencyHistogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (histogram *LatencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(histogram.Bounds) && d > histogram.Bounds[i] {
+		i++
+	}
+	histogram.Counts[i]++
+	histogram.Count++
+	histogram.Sum += d
+}
+
+func (histogram *LatencyHistogram) clone() LatencyHistogram {
+	result := *histogram
+	result.Bounds = append([]time.Duration(nil), histogram.Bounds...)
+	result.Counts = append([]uint64(nil), histogram.Counts...)
+	return result
+}
+
+func (histogram *LatencyHistogram) Mean() time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+	return histogram.Sum / time.Duration(histogram.Count)
+}
+
+// PoolStats is a snapshot of a RoutinesPool
+type PoolStats struct {
+	Workers  int
+	CoreSize int
+	MaxSize  int
+	Queued   int
+	Active   int
+	// Submitted counts every task handed to an open pool, including the ones rejected afterwards
+	Submitted uint64
+	// Completed counts the tasks which returned or panicked
+	Completed uint64
+	// Rejected counts the tasks refused by a closed pool, given up by SubmitContext or handed to a rejection policy
+	Rejected    uint64
+	Panics      uint64
+	WaitLatency LatencyHistogram
+	RunLatency  LatencyHistogram
+}
+
+// PoolHook is told about every task of a pool, it is called from submitting and worker goroutines
+// and must be safe for concurrent use
+type PoolHook interface {
+	TaskSubmitted()
+	TaskRejected()
+	TaskStarted(wait time.Duration)
+	TaskFinished(run time.Duration, panicked bool)
+}
+
+type poolStats struct {
+	// Accessed atomically, kept first for 64-bit alignment
+	submitted uint64
+	completed uint64
+	rejected  uint64
+	panics    uint64
+	running   int64
+
+	mutex sync.Mutex
+	wait  LatencyHistogram
+	run   LatencyHistogram
+	hook  PoolHook
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{wait: newLatencyHistogram(), run: newLatencyHistogram()}
+}
+
+func (stats *poolStats) currentHook() PoolHook {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	return stats.hook
+}
+
+func (stats *poolStats) taskRejected() {
+	atomic.AddUint64(&stats.rejected, 1)
+	if hook := stats.currentHook(); hook != nil {
+		hook.TaskRejected()
+	}
+}
+
+// instrument wraps task to measure how long it waited in the queue and how long it ran
+func (stats *poolStats) instrument(task func()) func() {
+	atomic.AddUint64(&stats.submitted, 1)
+	if hook := stats.currentHook(); hook != nil {
+		hook.TaskSubmitted()
+	}
+	submitted := time.Now()
+	return func() {
+		started := time.Now()
+		wait := started.Sub(submitted)
+		stats.mutex.Lock()
+		stats.wait.observe(wait)
+		hook := stats.hook
+		stats.mutex.Unlock()
+		if hook != nil {
+			hook.TaskStarted(wait)
+		}
+		atomic.AddInt64(&stats.running, 1)
+
+		finished := false
+		defer func() {
+			run := time.Since(started)
+			atomic.AddInt64(&stats.running, -1)
+			atomic.AddUint64(&stats.completed, 1)
+			stats.mutex.Lock()
+			stats.run.observe(run)
+			stats.mutex.Unlock()
+			if hook != nil {
+				hook.TaskFinished(run, !finished)
+			}
+		}()
+		task()
+		finished = true
+	}
+}
+
+// SetHook installs hook, nil removes it
+func (pool *RoutinesPool) SetHook(hook PoolHook) {
+	pool.stats.mutex.Lock()
+	pool.stats.hook = hook
+	pool.stats.mutex.Unlock()
+}
+
+func (pool *RoutinesPool) Stats() PoolStats {
+	result := PoolStats{
+		Queued:    len(pool.blockQueue),
+		Active:    int(atomic.LoadInt64(&pool.stats.running)),
+		Submitted: atomic.LoadUint64(&pool.stats.submitted),
+		Completed: atomic.LoadUint64(&pool.stats.completed),
+		Rejected:  atomic.LoadUint64(&pool.stats.rejected),
+		Panics:    atomic.LoadUint64(&pool.stats.panics),
+	}
+	pool.condition.L.Lock()
+	result.Workers = pool.workerCount
+	result.CoreSize = pool.coreSize
+	result.MaxSize = pool.maxSize
+	pool.condition.L.Unlock()
+	pool.stats.mutex.Lock()
+	result.WaitLatency = pool.stats.wait.clone()
+	result.RunLatency = pool.stats.run.clone()
+	pool.stats.mutex.Unlock()
+	return result
+}
+
+// PublishExpvar exports the stats under name on /debug/vars, expvar panics when name is already taken
+func (pool *RoutinesPool) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return pool.Stats()
+	}))
+}
+
+// WritePrometheus writes the stats in the Prometheus text exposition format,
+// every metric name starts with prefix and latencies are reported in seconds
+func (pool *RoutinesPool) WritePrometheus(w io.Writer, prefix string) error {
+	stats := pool.Stats()
+	gauges := []struct {
+		name  string
+		value int
+	}{
+		{"workers", stats.Workers},
+		{"queued_tasks", stats.Queued},
+		{"active_tasks", stats.Active},
+	}
+	for i := range gauges {
+		if _, e := fmt.Fprintf(w, "# TYPE %s_%s gauge\n%s_%s %d\n",
+			prefix, gauges[i].name, prefix, gauges[i].name, gauges[i].value); e != nil {
+			return e
+		}
+	}
+	counters := []struct {
+		name  string
+		value uint64
+	}{
+		{"submitted_tasks_total", stats.Submitted},
+		{"completed_tasks_total", stats.Completed},
+		{"rejected_tasks_total", stats.Rejected},
+		{"panics_total", stats.Panics},
+	}
+	for i := range counters {
+		if _, e := fmt.Fprintf(w, "# TYPE %s_%s counter\n%s_%s %d\n",
+			prefix, counters[i].name, prefix, counters[i].name, counters[i].value); e != nil {
+			return e
+		}
+	}
+	if e := writePrometheusHistogram(w, prefix+"_wait_seconds", &stats.WaitLatency); e != nil {
+		return e
+	}
+	return writePrometheusHistogram(w, prefix+"_run_seconds", &stats.RunLatency)
+}
+
+func writePrometheusHistogram(w io.Writer, name string, histogram *LatencyHistogram) error {
+	if _, e := fmt.Fprintf(w, "# TYPE %s histogram\n", name); e != nil {
+		return e
+	}
+	cumulative := uint64(0)
+	for i := range histogram.Bounds {
+		cumulative += histogram.Counts[i]
+		if _, e := fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, histogram.Bounds[i].Seconds(), cumulative); e != nil {
+			return e
+		}
+	}
+	_, e := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n",
+		name, histogram.Count, name, histogram.Sum.Seconds(), name, histogram.Count)
+	return e
+}
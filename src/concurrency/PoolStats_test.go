@@ -0,0 +1,140 @@
+package concurrency
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHook counts the calls it receives
+type countingHook struct {
+	submitted, rejected, started, finished, panicked int32
+}
+
+func (hook *countingHook) TaskSubmitted() { atomic.AddInt32(&hook.submitted, 1) }
+func (hook *countingHook) TaskRejected()  { atomic.AddInt32(&hook.rejected, 1) }
+func (hook *countingHook) TaskStarted(wait time.Duration) {
+	atomic.AddInt32(&hook.started, 1)
+}
+func (hook *countingHook) TaskFinished(run time.Duration, panicked bool) {
+	atomic.AddInt32(&hook.finished, 1)
+	if panicked {
+		atomic.AddInt32(&hook.panicked, 1)
+	}
+}
+
+func TestPoolStatsCounters(t *testing.T) {
+	pool := NewDynamicRoutinesPool(1, 1, 1, 0)
+	pool.SetPanicHandler(func(*PanicError) {})
+	hook := new(countingHook)
+	pool.SetHook(hook)
+	release := make(chan struct{})
+	occupy(t, pool, 1, release)
+	if e := pool.Submit(func() {}); e != nil {
+		t.Fatal(e)
+	}
+	if e := pool.TrySubmit(func() {}); e != ErrTaskRejected {
+		t.Fatalf("TrySubmit on a full pool returned %v", e)
+	}
+	stats := pool.Stats()
+	if stats.Workers != 1 || stats.CoreSize != 1 || stats.MaxSize != 1 || stats.Active != 1 || stats.Queued != 1 {
+		t.Fatalf("busy pool: got %+v", stats)
+	}
+
+	close(release)
+	if e := pool.Submit(panicTask); e != nil {
+		t.Fatal(e)
+	}
+	closeWithin(t, pool, 5*time.Second)
+	if e := pool.Submit(func() {}); e != ErrPoolClosed {
+		t.Fatalf("Submit after Close returned %v", e)
+	}
+	stats = pool.Stats()
+	for _, counter := range []struct {
+		name            string
+		value, expected uint64
+	}{
+		{"Submitted", stats.Submitted, 4},
+		{"Completed", stats.Completed, 3},
+		{"Rejected", stats.Rejected, 2},
+		{"Panics", stats.Panics, 1},
+		{"WaitLatency.Count", stats.WaitLatency.Count, 3},
+		{"RunLatency.Count", stats.RunLatency.Count, 3},
+	} {
+		if counter.value != counter.expected {
+			t.Errorf("%s = %d, want %d", counter.name, counter.value, counter.expected)
+		}
+	}
+	if stats.Active != 0 || stats.Queued != 0 || stats.Workers != 0 {
+		t.Errorf("closed pool: got %+v", stats)
+	}
+	if *hook != (countingHook{submitted: 4, rejected: 2, started: 3, finished: 3, panicked: 1}) {
+		t.Errorf("hook counted %+v", *hook)
+	}
+}
+
+func TestLatencyHistogramBuckets(t *testing.T) {
+	histogram := LatencyHistogram{
+		Bounds: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+		Counts: make([]uint64, 3),
+	}
+	if histogram.Mean() != 0 {
+		t.Fatalf("empty histogram has mean %v", histogram.Mean())
+	}
+	for _, d := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 10 * time.Millisecond, 11 * time.Millisecond} {
+		histogram.observe(d)
+	}
+	// Bounds are inclusive, the last bucket holds everything above them
+	for i, expected := range []uint64{1, 2, 1} {
+		if histogram.Counts[i] != expected {
+			t.Errorf("bucket %d counts %d, want %d", i, histogram.Counts[i], expected)
+		}
+	}
+	if histogram.Count != 4 || histogram.Mean() != 6*time.Millisecond {
+		t.Errorf("got count %d and mean %v, want 4 and 6ms", histogram.Count, histogram.Mean())
+	}
+}
+
+func TestDefaultLatencyBoundsAreCopied(t *testing.T) {
+	pool := NewRoutinesPool(1)
+	defer pool.Close()
+	first := DefaultLatencyBounds[0]
+	DefaultLatencyBounds[0] = time.Hour
+	defer func() {
+		DefaultLatencyBounds[0] = first
+	}()
+	stats := pool.Stats()
+	if stats.WaitLatency.Bounds[0] != first || stats.RunLatency.Bounds[0] != first {
+		t.Fatal("changing DefaultLatencyBounds changed the buckets of an existing pool")
+	}
+	stats.RunLatency.Bounds[0] = time.Hour
+	if pool.Stats().RunLatency.Bounds[0] != first {
+		t.Fatal("changing a snapshot changed the buckets of the pool")
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	pool := NewRoutinesPool(1)
+	if e := pool.Submit(func() {}); e != nil {
+		t.Fatal(e)
+	}
+	closeWithin(t, pool, 5*time.Second)
+	var output bytes.Buffer
+	if e := pool.WritePrometheus(&output, "pool"); e != nil {
+		t.Fatal(e)
+	}
+	for _, line := range []string{
+		"# TYPE pool_workers gauge\npool_workers 0\n",
+		"# TYPE pool_submitted_tasks_total counter\npool_submitted_tasks_total 1\n",
+		"pool_completed_tasks_total 1\n",
+		"# TYPE pool_run_seconds histogram\n",
+		"pool_run_seconds_bucket{le=\"+Inf\"} 1\npool_run_seconds_sum ",
+		"pool_wait_seconds_count 1\n",
+	} {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("output misses %q:\n%s", line, output.String())
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package concurrency
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// PriorityTask runs before every task of lower priority. A task still queued
+// once its Deadline has passed is dropped and handed to the expired handler instead.
+type PriorityTask struct {
+	Priority int
+	Deadline time.Time
+	Run      func()
+}
+
+type priorityEntry struct {
+	task     *PriorityTask
+	rank     int64
+	sequence uint64
+	index    int
+}
+
+type priorityQueue []*priorityEntry
+
+func (queue priorityQueue) Len() int {
+	return len(queue)
+}
+
+// Less puts the highest rank first, then the earliest deadline, then the earliest submission
+func (queue priorityQueue) Less(i, j int) bool {
+	a, b := queue[i], queue[j]
+	if a.rank != b.rank {
+		return a.rank > b.rank
+	}
+	if !a.task.Deadline.Equal(b.task.Deadline) {
+		if a.task.Deadline.IsZero() || b.task.Deadline.IsZero() {
+			return b.task.Deadline.IsZero()
+		}
+		return a.task.Deadline.Before(b.task.Deadline)
+	}
+	return a.sequence < b.sequence
+}
+
+func (queue priorityQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+	queue[i].index = i
+	queue[j].index = j
+}
+
+func (queue *priorityQueue) Push(x interface{}) {
+	entry := x.(*priorityEntry)
+	entry.index = len(*queue)
+	*queue = append(*queue, entry)
+}
+
+func (queue *priorityQueue) Pop() interface{} {
+	old := *queue
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*queue = old[:n-1]
+	entry.index = -1
+	return entry
+}
+
+// PriorityRoutinesPool orders tasks by priority on top of a RoutinesPool.
+// Every submission queues a placeholder in the pool, whichever worker picks a placeholder
+// up runs the best task waiting at that moment. When the rejection policy of the pool
+// discards a placeholder, the task which would have run last is dropped in its place.
+//
+// To keep low priority tasks from starving, waiting for aging counts as one priority level.
+// Since every waiting task ages at the same rate, the rank priority*aging - submitTime
+// orders them without ever being recomputed.
+type PriorityRoutinesPool struct {
+	pool     *RoutinesPool
+	mutex    sync.Mutex
+	queue    priorityQueue
+	aging    time.Duration
+	sequence uint64
+	expired  func(task *PriorityTask)
+}
+
+// NewPriorityRoutinesPool schedules on pool, a non-positive aging turns starvation protection off
+func NewPriorityRoutinesPool(pool *RoutinesPool, aging time.Duration) *PriorityRoutinesPool {
+	return &PriorityRoutinesPool{
+		pool:  pool,
+		queue: make(priorityQueue, 0),
+		aging: aging,
+	}
+}
+
+// SetExpiredHandler is called for every task dropped, because of its deadline
+// or because the rejection policy of the pool or ShutdownNow discarded its placeholder
+func (p *PriorityRoutinesPool) SetExpiredHandler(handler func(task *PriorityTask)) {
+	p.mutex.Lock()
+	p.expired = handler
+	p.mutex.Unlock()
+}
+
+func (p *PriorityRoutinesPool) Submit(priority int, task func()) error {
+	return p.SubmitTask(&PriorityTask{Priority: priority, Run: task})
+}
+
+// SubmitTask fails like Submit of the underlying pool, unless a worker picked task up meanwhile
+func (p *PriorityRoutinesPool) SubmitTask(task *PriorityTask) error {
+	entry := &priorityEntry{task: task}
+	p.mutex.Lock()
+	if p.aging > 0 {
+		entry.rank = int64(task.Priority)*int64(p.aging) - time.Now().UnixNano()
+	} else {
+		entry.rank = int64(task.Priority)
+	}
+	entry.sequence = p.sequence
+	p.sequence++
+	heap.Push(&p.queue, entry)
+	p.mutex.Unlock()
+
+	e := p.pool.submit(nil, &poolTask{run: p.runNext, discarded: p.dropLast}, submitWait)
+	if e != nil {
+		p.mutex.Lock()
+		if entry.index >= 0 {
+			heap.Remove(&p.queue, entry.index)
+			p.mutex.Unlock()
+			return e
+		}
+		p.mutex.Unlock()
+		// The placeholder of another task already ran this one, which stays submitted.
+		// That task is left without a placeholder, the one which would run last is dropped instead.
+		p.dropLast(e)
+	}
+	return nil
+}
+
+// Len returns the number of tasks which have not started yet
+func (p *PriorityRoutinesPool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.queue)
+}
+
+// Pool returns the underlying pool, used to shut it down
+func (p *PriorityRoutinesPool) Pool() *RoutinesPool {
+	return p.pool
+}
+
+// dropLast removes the task which would run last, one placeholder less is left in the pool
+func (p *PriorityRoutinesPool) dropLast(e error) {
+	p.mutex.Lock()
+	if len(p.queue) == 0 {
+		p.mutex.Unlock()
+		return
+	}
+	last := 0
+	for i := range p.queue {
+		if p.queue.Less(last, i) {
+			last = i
+		}
+	}
+	task := heap.Remove(&p.queue, last).(*priorityEntry).task
+	expired := p.expired
+	p.mutex.Unlock()
+
+	if expired != nil {
+		expired(task)
+	}
+}
+
+func (p *PriorityRoutinesPool) runNext() {
+	p.mutex.Lock()
+	if len(p.queue) == 0 {
+		p.mutex.Unlock()
+		return
+	}
+	task := heap.Pop(&p.queue).(*priorityEntry).task
+	expired := p.expired
+	p.mutex.Unlock()
+
+	if !task.Deadline.IsZero() && time.Now().After(task.Deadline) {
+		if expired != nil {
+			expired(task)
+		}
+		return
+	}
+	task.Run()
+}
@@ -0,0 +1,66 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriorityRoutinesPoolRunsHighestFirst(t *testing.T) {
+	pool := NewPriorityRoutinesPool(NewDynamicRoutinesPool(1, 1, 8, 0), 0)
+	defer pool.Pool().Close()
+	release := make(chan struct{})
+	occupy(t, pool.Pool(), 1, release)
+	order := make(chan int, 4)
+	for _, priority := range []int{1, 3, 2, 3} {
+		priority := priority
+		if e := pool.Submit(priority, func() { order <- priority }); e != nil {
+			t.Fatal(e)
+		}
+	}
+	close(release)
+	for _, expected := range []int{3, 3, 2, 1} {
+		select {
+		case priority := <-order:
+			if priority != expected {
+				t.Fatalf("ran priority %d, want %d", priority, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued tasks do not run")
+		}
+	}
+}
+
+func TestPriorityRoutinesPoolRejectedAfterRunning(t *testing.T) {
+	pool := NewPriorityRoutinesPool(NewDynamicRoutinesPool(1, 1, 1, 0), 0)
+	defer pool.Pool().Close()
+	release := make(chan struct{})
+	occupy(t, pool.Pool(), 1, release)
+	expired := make(chan *PriorityTask, 2)
+	pool.SetExpiredHandler(func(task *PriorityTask) { expired <- task })
+	low := &PriorityTask{Priority: 1, Run: func() { t.Error("the task without a placeholder ran") }}
+	if e := pool.SubmitTask(low); e != nil {
+		t.Fatal(e)
+	}
+
+	// The placeholder of the low task runs the high one while its own placeholder is being rejected
+	ran := make(chan struct{})
+	pool.Pool().SetRejectionPolicy(func(*RoutinesPool, func()) error {
+		close(release)
+		<-ran
+		return ErrTaskRejected
+	})
+	if e := pool.Submit(2, func() { close(ran) }); e != nil {
+		t.Fatalf("Submit of a task which ran returned %v", e)
+	}
+	select {
+	case task := <-expired:
+		if task != low {
+			t.Fatalf("dropped priority %d, want the low task", task.Priority)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the task left without a placeholder was not dropped")
+	}
+	if n := pool.Len(); n != 0 {
+		t.Fatalf("%d tasks left waiting for a placeholder", n)
+	}
+}
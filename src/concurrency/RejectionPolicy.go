@@ -0,0 +1,92 @@
+package concurrency
+
+import "errors"
+
+var (
+	ErrPoolClosed    = errors.New("concurrency: routines pool closed")
+	ErrTaskRejected  = errors.New("concurrency: task rejected")
+	ErrTaskDiscarded = errors.New("concurrency: task discarded")
+
+	// errReplaceOldest asks reject to queue the task in place of the oldest one
+	errReplaceOldest = errors.New("concurrency: replace the oldest task")
+)
+
+// RejectionPolicy decides what happens to a task submitted while the queue is full
+// and the pool already runs maxSize workers. A nil error means the task was accepted.
+// ErrTaskDiscarded drops the task while the submission still succeeds, whatever waits for the task
+// is settled with ErrTaskDiscarded instead: futures complete with it, ErrGroup fails with it
+// and TrySubmitOrElse calls its fallback.
+type RejectionPolicy func(pool *RoutinesPool, task func()) error
+
+// AbortPolicy fails the submission with ErrTaskRejected
+func AbortPolicy(pool *RoutinesPool, task func()) error {
+	return ErrTaskRejected
+}
+
+// CallerRunsPolicy runs the task in the submitting goroutine, which slows submitters down
+func CallerRunsPolicy(pool *RoutinesPool, task func()) error {
+	task()
+	return nil
+}
+
+// DiscardPolicy drops the task, Submit does not report it
+func DiscardPolicy(pool *RoutinesPool, task func()) error {
+	return ErrTaskDiscarded
+}
+
+// DiscardOldestPolicy drops the task which has been waiting the longest and queues this one instead,
+// it rejects the task when the queue has no capacity at all
+func DiscardOldestPolicy(pool *RoutinesPool, task func()) error {
+	if cap(pool.blockQueue) == 0 {
+		return ErrTaskRejected
+	}
+	return errReplaceOldest
+}
+
+// SetRejectionPolicy sets how Submit handles a full pool, nil restores blocking
+func (pool *RoutinesPool) SetRejectionPolicy(policy RejectionPolicy) {
+	pool.condition.L.Lock()
+	pool.rejectionPolicy = policy
+	pool.condition.L.Unlock()
+}
+
+// reject runs policy for a task which has already been counted as submitted.
+// No lock is held, so a task run by CallerRunsPolicy may submit to the pool again.
+func (pool *RoutinesPool) reject(policy RejectionPolicy, task *poolTask) error {
+	defer pool.finishTask()
+	pool.stats.taskRejected()
+	switch e := policy(pool, task.run); e {
+	case ErrTaskDiscarded:
+		task.discard(ErrTaskDiscarded)
+		return nil
+	case errReplaceOldest:
+		return pool.replaceOldest(task)
+	default:
+		return e
+	}
+}
+
+// replaceOldest queues task, discarding the tasks waiting the longest until it fits.
+// It never blocks, so it keeps submitLock for reading and the queue open.
+func (pool *RoutinesPool) replaceOldest(task *poolTask) error {
+	pool.submitLock.RLock()
+	defer pool.submitLock.RUnlock()
+	if pool.closed {
+		return ErrPoolClosed
+	}
+	// The queued copy is counted on its own, reject settles the original submission
+	pool.beginTask()
+	for {
+		select {
+		case pool.blockQueue <- task:
+			return nil
+		default:
+		}
+		select {
+		case oldest := <-pool.blockQueue:
+			pool.finishTask()
+			oldest.discard(ErrTaskDiscarded)
+		default:
+		}
+	}
+}
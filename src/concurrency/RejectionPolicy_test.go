@@ -0,0 +1,302 @@
+package concurrency
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockedPool returns a pool of one worker busy until release is closed, with one queued task
+func blockedPool(t *testing.T, policy RejectionPolicy) (*RoutinesPool, chan struct{}) {
+	pool := NewDynamicRoutinesPool(1, 1, 1, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	if e := pool.Submit(func() {}); e != nil {
+		t.Fatal(e)
+	}
+	pool.SetRejectionPolicy(policy)
+	return pool, release
+}
+
+// getWithin fails the test when future has not completed after a while
+func getWithin(t *testing.T, future *Future) (interface{}, error) {
+	t.Helper()
+	select {
+	case <-future.Done():
+		return future.Get()
+	case <-time.After(10 * time.Second):
+		t.Fatal("future never completed")
+		return nil, nil
+	}
+}
+
+func TestAbortPolicy(t *testing.T) {
+	pool, release := blockedPool(t, nil)
+	if e := pool.TrySubmit(func() {}); e != ErrTaskRejected {
+		t.Fatalf("TrySubmit returned %v", e)
+	}
+	pool.SetRejectionPolicy(AbortPolicy)
+	if e := pool.Submit(func() {}); e != ErrTaskRejected {
+		t.Fatalf("Submit returned %v", e)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if e := pool.SubmitContext(ctx, func() {}); e != context.DeadlineExceeded {
+		t.Fatalf("SubmitContext returned %v", e)
+	}
+	close(release)
+	closeWithin(t, pool, 10*time.Second)
+	if e := pool.Submit(func() {}); e != ErrPoolClosed {
+		t.Fatalf("Submit after Close returned %v", e)
+	}
+	if _, e := pool.SubmitWithResult(func() (interface{}, error) { return nil, nil }).Get(); e != ErrPoolClosed {
+		t.Fatalf("future of a closed pool completed with %v", e)
+	}
+}
+
+func TestCallerRunsPolicy(t *testing.T) {
+	pool, release := blockedPool(t, CallerRunsPolicy)
+	ran := false
+	if e := pool.Submit(func() { ran = true }); e != nil || !ran {
+		t.Fatalf("Submit returned %v, task ran: %v", e, ran)
+	}
+	close(release)
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestCallerRunsPolicyTaskSubmitsDuringShutdown(t *testing.T) {
+	pool, release := blockedPool(t, CallerRunsPolicy)
+	running := make(chan struct{})
+	shuttingDown := make(chan struct{})
+	nested := make(chan error, 1)
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- pool.Submit(func() {
+			close(running)
+			<-shuttingDown
+			// Used to wait for submitLock, which Shutdown was waiting for behind this very task
+			nested <- pool.Submit(func() {})
+		})
+	}()
+	<-running
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- pool.Shutdown(context.Background())
+	}()
+	eventually(t, 5*time.Second, pool.IsShutdown, "Shutdown did not start")
+	close(shuttingDown)
+	select {
+	case e := <-nested:
+		if e != ErrPoolClosed {
+			t.Fatalf("nested Submit returned %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nested Submit of a caller-run task deadlocked with Shutdown")
+	}
+	if e := <-submitted; e != nil {
+		t.Fatalf("Submit returned %v", e)
+	}
+	close(release)
+	if e := <-shutdown; e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestBlockedSubmitFailsOnShutdown(t *testing.T) {
+	pool, release := blockedPool(t, nil)
+	defer close(release)
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- pool.Submit(func() {})
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	contextSubmitted := make(chan error, 1)
+	go func() {
+		contextSubmitted <- pool.SubmitContext(ctx, func() {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// Shutting down must not wait for the busy worker to make room for them
+	if pending := pool.ShutdownNow(); len(pending) != 1 {
+		t.Fatalf("ShutdownNow returned %d pending tasks, want the queued one", len(pending))
+	}
+	for _, result := range []chan error{submitted, contextSubmitted} {
+		select {
+		case e := <-result:
+			if e != ErrPoolClosed {
+				t.Fatalf("blocked submission returned %v", e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocked submission still waits after ShutdownNow")
+		}
+	}
+}
+
+func TestDiscardPolicySettlesTasks(t *testing.T) {
+	pool, release := blockedPool(t, DiscardPolicy)
+	var ran int64
+	if e := pool.Submit(func() { atomic.AddInt64(&ran, 1) }); e != nil {
+		t.Fatalf("Submit returned %v", e)
+	}
+	future := pool.SubmitWithResult(func() (interface{}, error) {
+		atomic.AddInt64(&ran, 1)
+		return nil, nil
+	})
+	if _, e := getWithin(t, future); e != ErrTaskDiscarded {
+		t.Fatalf("discarded future completed with %v", e)
+	}
+	fallback := make(chan error, 1)
+	if e := pool.TrySubmitOrElse(func() { atomic.AddInt64(&ran, 1) }, func(e error) { fallback <- e }); e != nil {
+		t.Fatalf("TrySubmitOrElse returned %v", e)
+	}
+	if e := <-fallback; e != ErrTaskDiscarded {
+		t.Fatalf("fallback called with %v", e)
+	}
+	close(release)
+	closeWithin(t, pool, 10*time.Second)
+	if ran != 0 {
+		t.Fatalf("%d discarded tasks ran", ran)
+	}
+}
+
+func TestDiscardOldestPolicySettlesTasks(t *testing.T) {
+	pool := NewDynamicRoutinesPool(1, 1, 1, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	pool.SetRejectionPolicy(DiscardOldestPolicy)
+	oldest := pool.SubmitWithResult(func() (interface{}, error) { return "oldest", nil })
+	newest := pool.SubmitWithResult(func() (interface{}, error) { return "newest", nil })
+	if _, e := getWithin(t, oldest); e != ErrTaskDiscarded {
+		t.Fatalf("oldest future completed with %v", e)
+	}
+	close(release)
+	if value, e := getWithin(t, newest); e != nil || value != "newest" {
+		t.Fatalf("newest future completed with %v, %v", value, e)
+	}
+	closeWithin(t, pool, 10*time.Second)
+
+	// Without a queue there is nothing to replace
+	pool = NewDynamicRoutinesPool(1, 1, 0, 0)
+	release = make(chan struct{})
+	pool.Submit(func() { <-release })
+	pool.SetRejectionPolicy(DiscardOldestPolicy)
+	if e := pool.Submit(func() {}); e != ErrTaskRejected {
+		t.Fatalf("Submit returned %v", e)
+	}
+	close(release)
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestInvokeAllWithDiscardingPool(t *testing.T) {
+	for _, policy := range []RejectionPolicy{DiscardPolicy, DiscardOldestPolicy} {
+		pool := NewDynamicRoutinesPool(1, 1, 1, 0)
+		pool.SetRejectionPolicy(policy)
+		tasks := make([]Callable, 20)
+		for i := range tasks {
+			i := i
+			tasks[i] = func() (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				return i, nil
+			}
+		}
+		done := make(chan []*Future)
+		go func() {
+			done <- pool.InvokeAll(tasks)
+		}()
+		select {
+		case futures := <-done:
+			for i, future := range futures {
+				if value, e := future.Get(); e != ErrTaskDiscarded && (e != nil || value != i) {
+					t.Fatalf("task %d completed with %v, %v", i, value, e)
+				}
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("InvokeAll never returned")
+		}
+		if _, e := pool.InvokeAny(tasks); e != nil && e != ErrTaskDiscarded {
+			t.Fatalf("InvokeAny returned %v", e)
+		}
+		closeWithin(t, pool, 10*time.Second)
+	}
+}
+
+func TestErrGroupWithDiscardingPool(t *testing.T) {
+	pool := NewDynamicRoutinesPool(1, 1, 1, 0)
+	release := make(chan struct{})
+	pool.Submit(func() { <-release })
+	group, _ := NewErrGroup(context.Background())
+	if e := group.GoPool(pool, func() error { return nil }); e != nil {
+		t.Fatal(e)
+	}
+	// The queued group task is the oldest one, and gets replaced
+	pool.SetRejectionPolicy(DiscardOldestPolicy)
+	pool.Submit(func() {})
+	close(release)
+	done := make(chan error)
+	go func() {
+		done <- group.Wait()
+	}()
+	select {
+	case e := <-done:
+		if e != ErrTaskDiscarded {
+			t.Fatalf("Wait returned %v", e)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wait never returned")
+	}
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestPriorityRoutinesPoolWithDiscardingPool(t *testing.T) {
+	pool, release := blockedPool(t, DiscardPolicy)
+	priorityPool := NewPriorityRoutinesPool(pool, 0)
+	dropped := make(chan *PriorityTask, 1)
+	priorityPool.SetExpiredHandler(func(task *PriorityTask) {
+		dropped <- task
+	})
+	var ran int64
+	priorityPool.Submit(9, func() { atomic.AddInt64(&ran, 1) })
+	if task := <-dropped; task.Priority != 9 {
+		t.Fatalf("dropped the task of priority %d", task.Priority)
+	}
+	if priorityPool.Len() != 0 {
+		t.Fatalf("%d tasks left waiting", priorityPool.Len())
+	}
+	close(release)
+	closeWithin(t, pool, 10*time.Second)
+	if ran != 0 {
+		t.Fatal("discarded task ran")
+	}
+}
+
+func TestScheduledPoolWithDiscardingPool(t *testing.T) {
+	pool, release := blockedPool(t, DiscardPolicy)
+	scheduled := NewScheduledPool(pool)
+	var ran int64
+	task := scheduled.ScheduleWithFixedDelay(func() { atomic.AddInt64(&ran, 1) }, time.Millisecond, time.Millisecond)
+	// Runs are discarded while the worker is busy, the task keeps being planned
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt64(&ran) != 0 || task.NextRun().IsZero() {
+		t.Fatalf("ran %d times, next run %v", ran, task.NextRun())
+	}
+	close(release)
+	for deadline := time.Now().Add(10 * time.Second); atomic.LoadInt64(&ran) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("task never ran once the pool had room")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	scheduled.Close()
+	closeWithin(t, pool, 10*time.Second)
+}
@@ -1,51 +1,502 @@
 package concurrency
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// PanicError carries the value a task panicked with and the stack of the panic
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func newPanicError(value interface{}) *PanicError {
+	if e, ok := value.(*PanicError); ok {
+		return e
+	}
+	return &PanicError{value, debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("concurrency: task panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// RoutinesPool runs tasks on at least coreSize and at most maxSize goroutines.
+// Workers beyond coreSize are only started when the queue is full,
+// and exit again after staying idle for keepAlive.
 type RoutinesPool struct {
 	activeWorker int
+	workerCount  int
+	coreSize     int
+	maxSize      int
+	keepAlive    time.Duration
 	condition    *sync.Cond
-	blockQueue   chan func()
+	blockQueue   chan *poolTask
+	resized      chan struct{}
+	panicHandler func(e *PanicError)
+
+	// submitLock is held for reading while a task is offered without blocking.
+	// Submitters which have to wait count themselves in senders and release it,
+	// blockQueue is only closed once closing has sent every one of them away.
+	// closed is written holding both submitLock and condition.L.
+	submitLock      sync.RWMutex
+	closed          bool
+	closing         chan struct{}
+	senders         int
+	closeQueue      sync.Once
+	rejectionPolicy RejectionPolicy
+
+	// ctx is handed to cancellable tasks and cancelled when shutdown gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stats *poolStats
+}
+
+// poolTask is a submitted task, discarded settles it when it is dropped without running,
+// by a rejection policy or by ShutdownNow
+type poolTask struct {
+	run       func()
+	discarded func(e error)
+}
+
+func (task *poolTask) discard(e error) {
+	if task.discarded != nil {
+		// The fallback may well submit again or block, the submitter must not wait for it
+		go task.discarded(e)
+	}
+}
+
+func defaultPanicHandler(e *PanicError) {
+	log.Println(e)
 }
 
-func workHandler(pool *RoutinesPool) {
-	// Consider channel as a block queue,
-	// and range like poll method
-	for task := range pool.blockQueue {
+// workHandler runs first, when given, then keeps polling the queue
+func workHandler(pool *RoutinesPool, first func()) {
+	// A panicking task kills this goroutine, so start a replacement,
+	// report the panic and only then account for the task, Close waits for the report
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			e := newPanicError(recovered)
+			atomic.AddUint64(&pool.stats.panics, 1)
+			go workHandler(pool, nil)
+			pool.condition.L.Lock()
+			handler := pool.panicHandler
+			pool.condition.L.Unlock()
+			handler(e)
+			pool.finishTask()
+		}
+	}()
+	if first != nil {
+		first()
+		pool.finishTask()
+	}
+	for {
+		task, ok := pool.poll()
+		if !ok {
+			return
+		}
 		task()
+		pool.finishTask()
+	}
+}
+
+// poll takes the next task from the queue, false means the worker has been retired
+func (pool *RoutinesPool) poll() (func(), bool) {
+	for {
 		pool.condition.L.Lock()
-		pool.activeWorker--
-		if !(pool.activeWorker > 0) {
-			pool.condition.Signal()
+		if pool.workerCount > pool.maxSize {
+			pool.exitWorker()
+			pool.condition.L.Unlock()
+			return nil, false
 		}
+		surplus := pool.workerCount > pool.coreSize
+		keepAlive := pool.keepAlive
+		resized := pool.resized
 		pool.condition.L.Unlock()
+
+		// Consider channel as a block queue,
+		// and receive like poll method
+		if !surplus {
+			select {
+			case task, ok := <-pool.blockQueue:
+				return pool.received(task, ok)
+			case <-resized:
+				continue
+			}
+		}
+		if keepAlive <= 0 {
+			select {
+			case task, ok := <-pool.blockQueue:
+				return pool.received(task, ok)
+			default:
+			}
+			if pool.retireSurplus() {
+				return nil, false
+			}
+			continue
+		}
+		timer := time.NewTimer(keepAlive)
+		select {
+		case task, ok := <-pool.blockQueue:
+			timer.Stop()
+			return pool.received(task, ok)
+		case <-timer.C:
+			if pool.retireSurplus() {
+				return nil, false
+			}
+		case <-resized:
+			timer.Stop()
+		}
+	}
+}
+
+func (pool *RoutinesPool) received(task *poolTask, ok bool) (func(), bool) {
+	if !ok {
+		pool.condition.L.Lock()
+		pool.exitWorker()
+		pool.condition.L.Unlock()
+		return nil, false
+	}
+	return task.run, true
+}
+
+// retireSurplus lets the calling worker exit if the pool still has more than coreSize workers.
+// The last worker stays while tasks are queued, Submit only starts one when it sees none left.
+func (pool *RoutinesPool) retireSurplus() bool {
+	pool.condition.L.Lock()
+	defer pool.condition.L.Unlock()
+	if pool.workerCount == 1 && len(pool.blockQueue) > 0 {
+		return false
+	}
+	if pool.workerCount > pool.coreSize {
+		pool.exitWorker()
+		return true
 	}
+	return false
+}
+
+// exitWorker must be called with condition.L held, AwaitTermination watches the worker count
+func (pool *RoutinesPool) exitWorker() {
+	pool.workerCount--
+	pool.condition.Broadcast()
 }
 
+// startWorker must be called with condition.L held
+func (pool *RoutinesPool) startWorker(first func()) {
+	pool.workerCount++
+	go workHandler(pool, first)
+}
+
+func (pool *RoutinesPool) finishTask() {
+	pool.condition.L.Lock()
+	pool.activeWorker--
+	if !(pool.activeWorker > 0) {
+		pool.condition.Broadcast()
+	}
+	pool.condition.L.Unlock()
+}
+
+// NewRoutinesPool starts size workers sharing a queue of size tasks
 func NewRoutinesPool(size int) *RoutinesPool {
+	return NewDynamicRoutinesPool(size, size, size, 0)
+}
+
+// NewDynamicRoutinesPool starts coreSize workers, and grows up to maxSize workers
+// whenever queueCapacity tasks are already waiting
+func NewDynamicRoutinesPool(coreSize, maxSize, queueCapacity int, keepAlive time.Duration) *RoutinesPool {
+	if coreSize < 0 || maxSize < 1 || coreSize > maxSize || queueCapacity < 0 {
+		panic("concurrency: invalid RoutinesPool size")
+	}
 	instance := new(RoutinesPool)
+	instance.coreSize = coreSize
+	instance.maxSize = maxSize
+	instance.keepAlive = keepAlive
 	instance.condition = sync.NewCond(&sync.Mutex{})
-	instance.blockQueue = make(chan func(), size)
-	for i := 0; i < size; i++ {
-		go workHandler(instance)
+	instance.blockQueue = make(chan *poolTask, queueCapacity)
+	instance.resized = make(chan struct{})
+	instance.closing = make(chan struct{})
+	instance.panicHandler = defaultPanicHandler
+	instance.ctx, instance.cancel = context.WithCancel(context.Background())
+	instance.stats = newPoolStats()
+	instance.condition.L.Lock()
+	for i := 0; i < coreSize; i++ {
+		instance.startWorker(nil)
 	}
+	instance.condition.L.Unlock()
 	return instance
 }
 
-func (pool *RoutinesPool) Submit(task func()) {
+// Resize changes the worker limits at runtime, surplus workers exit once their current task is done
+func (pool *RoutinesPool) Resize(coreSize, maxSize int) {
+	if coreSize < 0 || maxSize < 1 || coreSize > maxSize {
+		panic("concurrency: invalid RoutinesPool size")
+	}
+	pool.condition.L.Lock()
+	pool.coreSize = coreSize
+	pool.maxSize = maxSize
+	for pool.workerCount < coreSize {
+		pool.startWorker(nil)
+	}
+	// Wake idle workers so they re-check the limits
+	close(pool.resized)
+	pool.resized = make(chan struct{})
+	pool.condition.L.Unlock()
+}
+
+// SetKeepAlive changes how long surplus workers wait for a task before exiting
+func (pool *RoutinesPool) SetKeepAlive(keepAlive time.Duration) {
+	pool.condition.L.Lock()
+	pool.keepAlive = keepAlive
+	close(pool.resized)
+	pool.resized = make(chan struct{})
+	pool.condition.L.Unlock()
+}
+
+// WorkerCount returns the number of live worker goroutines
+func (pool *RoutinesPool) WorkerCount() int {
+	pool.condition.L.Lock()
+	defer pool.condition.L.Unlock()
+	return pool.workerCount
+}
+
+// SetPanicHandler replaces the default handler, which logs the panic and its stack
+func (pool *RoutinesPool) SetPanicHandler(handler func(e *PanicError)) {
+	if handler == nil {
+		handler = defaultPanicHandler
+	}
+	pool.condition.L.Lock()
+	pool.panicHandler = handler
+	pool.condition.L.Unlock()
+}
+
+// Submit blocks while the queue is full and no more workers may be started,
+// unless a rejection policy has been set. It fails with ErrPoolClosed if the pool shuts down meanwhile.
+func (pool *RoutinesPool) Submit(task func()) error {
+	return pool.submit(nil, &poolTask{run: task}, submitWait)
+}
+
+// TrySubmit never waits for room in the queue, a task which does not fit goes to
+// the rejection policy, or is rejected with ErrTaskRejected when there is none
+func (pool *RoutinesPool) TrySubmit(task func()) error {
+	return pool.submit(nil, &poolTask{run: task}, submitTry)
+}
+
+// TrySubmitOrElse is TrySubmit for a task which must not get lost silently:
+// when the rejection policy discards it, orElse is called with ErrTaskDiscarded in a goroutine of its own
+func (pool *RoutinesPool) TrySubmitOrElse(task func(), orElse func(e error)) error {
+	return pool.submit(nil, &poolTask{task, orElse}, submitTry)
+}
+
+// SubmitContext waits for room in the queue until ctx is done, the rejection policy is not consulted
+func (pool *RoutinesPool) SubmitContext(ctx context.Context, task func()) error {
+	return pool.submit(ctx, &poolTask{run: task}, submitContext)
+}
+
+// submitMode tells submit what to do when the queue is full and no more workers may be started
+type submitMode int
+
+const (
+	// submitWait waits for room, unless a rejection policy has been set
+	submitWait submitMode = iota
+	// submitTry goes to the rejection policy, AbortPolicy when there is none
+	submitTry
+	// submitContext waits for room until ctx is done
+	submitContext
+)
+
+// submit never holds submitLock while it blocks or runs the rejection policy,
+// a task run by CallerRunsPolicy may submit again while Shutdown waits for the lock
+func (pool *RoutinesPool) submit(ctx context.Context, task *poolTask, mode submitMode) error {
+	pool.submitLock.RLock()
+	if pool.closed {
+		pool.submitLock.RUnlock()
+		pool.stats.taskRejected()
+		return ErrPoolClosed
+	}
+	task.run = pool.stats.instrument(task.run)
+	pool.beginTask()
+	if pool.offer(task) {
+		pool.submitLock.RUnlock()
+		return nil
+	}
+	pool.condition.L.Lock()
+	policy := pool.rejectionPolicy
+	wait := mode == submitContext || mode == submitWait && policy == nil
+	if wait {
+		pool.senders++
+	}
+	pool.condition.L.Unlock()
+	pool.submitLock.RUnlock()
+
+	if !wait {
+		if policy == nil {
+			policy = AbortPolicy
+		}
+		return pool.reject(policy, task)
+	}
+	defer pool.exitSender()
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case pool.blockQueue <- task:
+		return nil
+	case <-done:
+		pool.finishTask()
+		pool.stats.taskRejected()
+		return ctx.Err()
+	case <-pool.closing:
+		pool.finishTask()
+		pool.stats.taskRejected()
+		return ErrPoolClosed
+	}
+}
+
+func (pool *RoutinesPool) exitSender() {
+	pool.condition.L.Lock()
+	pool.senders--
+	pool.condition.Broadcast()
+	pool.condition.L.Unlock()
+}
+
+func (pool *RoutinesPool) beginTask() {
 	pool.condition.L.Lock()
 	pool.activeWorker++
 	pool.condition.L.Unlock()
-	pool.blockQueue <- task
 }
 
-func (pool *RoutinesPool) Close() {
+// offer places task without blocking, submitLock must be held for reading
+func (pool *RoutinesPool) offer(task *poolTask) bool {
 	pool.condition.L.Lock()
-	for pool.activeWorker > 0 {
+	if pool.workerCount < pool.coreSize {
+		pool.startWorker(task.run)
+		pool.condition.L.Unlock()
+		return true
+	}
+	pool.condition.L.Unlock()
+
+	select {
+	case pool.blockQueue <- task:
+		// Without core workers somebody still has to pick the task up
+		pool.condition.L.Lock()
+		if pool.workerCount == 0 {
+			pool.startWorker(nil)
+		}
+		pool.condition.L.Unlock()
+		return true
+	default:
+	}
+	// The queue is full, hand the task to a new worker
+	pool.condition.L.Lock()
+	defer pool.condition.L.Unlock()
+	if pool.workerCount < pool.maxSize {
+		pool.startWorker(task.run)
+		return true
+	}
+	return false
+}
+
+// SubmitCancellable runs a task which should return soon after ctx is cancelled,
+// that happens on ShutdownNow or when Shutdown runs out of time
+func (pool *RoutinesPool) SubmitCancellable(task func(ctx context.Context)) error {
+	return pool.Submit(func() {
+		task(pool.ctx)
+	})
+}
+
+// Context is the context given to cancellable tasks
+func (pool *RoutinesPool) Context() context.Context {
+	return pool.ctx
+}
+
+// shutdown refuses new tasks and lets the workers exit once the queue is drained
+func (pool *RoutinesPool) shutdown() {
+	pool.submitLock.Lock()
+	pool.condition.L.Lock()
+	if !pool.closed {
+		pool.closed = true
+		close(pool.closing)
+	}
+	pool.submitLock.Unlock()
+	// Blocked submitters give up on closing, queued tasks are still delivered after close
+	for pool.senders > 0 {
 		pool.condition.Wait()
 	}
 	pool.condition.L.Unlock()
-	close(pool.blockQueue)
+	pool.closeQueue.Do(func() {
+		close(pool.blockQueue)
+	})
+}
+
+// Shutdown refuses new tasks and waits until the queued and running ones are finished
+// and every worker has exited. When ctx is done first, the task context is cancelled
+// and ctx.Err() is returned while the workers keep draining the queue.
+func (pool *RoutinesPool) Shutdown(ctx context.Context) error {
+	pool.shutdown()
+	if e := pool.AwaitTermination(ctx); e != nil {
+		pool.cancel()
+		return e
+	}
+	pool.cancel()
+	return nil
+}
+
+// ShutdownNow refuses new tasks, cancels the task context and returns the tasks which never started.
+// Tasks waited on through a Future, an ErrGroup, a PriorityRoutinesPool or a ScheduledPool
+// are settled with ErrPoolClosed instead of being returned.
+// Running tasks are not interrupted, use AwaitTermination to wait for them.
+func (pool *RoutinesPool) ShutdownNow() []func() {
+	pool.shutdown()
+	pool.cancel()
+	pending := make([]func(), 0)
+	for {
+		task, ok := <-pool.blockQueue
+		if !ok {
+			return pending
+		}
+		if task.discarded != nil {
+			task.discard(ErrPoolClosed)
+		} else {
+			pending = append(pending, task.run)
+		}
+		pool.finishTask()
+	}
+}
+
+// AwaitTermination waits until the pool has been shut down and every worker has exited
+func (pool *RoutinesPool) AwaitTermination(ctx context.Context) error {
+	pool.condition.L.Lock()
+	defer pool.condition.L.Unlock()
+	return waitCondition(ctx, pool.condition, pool.terminated)
+}
+
+// terminated must be called with condition.L held
+func (pool *RoutinesPool) terminated() bool {
+	return pool.closed && pool.workerCount == 0 && pool.activeWorker == 0
+}
+
+func (pool *RoutinesPool) IsShutdown() bool {
+	pool.condition.L.Lock()
+	defer pool.condition.L.Unlock()
+	return pool.closed
+}
+
+func (pool *RoutinesPool) IsTerminated() bool {
+	pool.condition.L.Lock()
+	defer pool.condition.L.Unlock()
+	return pool.terminated()
+}
+
+// Close waits until every submitted task has finished and every worker has exited,
+// later submissions fail with ErrPoolClosed
+func (pool *RoutinesPool) Close() {
+	_ = pool.Shutdown(context.Background())
 }
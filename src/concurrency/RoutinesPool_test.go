@@ -0,0 +1,274 @@
+package concurrency
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeWithin fails the test when Close has not returned after timeout
+func closeWithin(t *testing.T, pool *RoutinesPool, timeout time.Duration) {
+	t.Helper()
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(timeout):
+		t.Fatal("Close did not return")
+	}
+}
+
+func panicTask() {
+	panic("boom")
+}
+
+func TestRoutinesPoolRecoversPanics(t *testing.T) {
+	pool := NewRoutinesPool(2)
+	var mutex sync.Mutex
+	reports := make([]*PanicError, 0)
+	pool.SetPanicHandler(func(e *PanicError) {
+		mutex.Lock()
+		reports = append(reports, e)
+		mutex.Unlock()
+	})
+	for i := 0; i < 20; i++ {
+		if e := pool.Submit(panicTask); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	// Workers are respawned, so the pool still runs tasks at full size
+	var group sync.WaitGroup
+	group.Add(2)
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		pool.Submit(func() {
+			group.Done()
+			<-release
+		})
+	}
+	group.Wait()
+	close(release)
+	if count := pool.WorkerCount(); count != 2 {
+		t.Fatalf("%d workers after panics, want 2", count)
+	}
+
+	closeWithin(t, pool, 10*time.Second)
+	if len(reports) != 20 {
+		t.Fatalf("%d panics reported, want 20", len(reports))
+	}
+	for _, report := range reports {
+		if report.Value != "boom" {
+			t.Fatalf("reported value %v", report.Value)
+		}
+		if !bytes.Contains(report.Stack, []byte("panicTask")) {
+			t.Fatalf("stack does not show the panicking task:\n%s", report.Stack)
+		}
+	}
+	if stats := pool.Stats(); stats.Panics != 20 || stats.Workers != 0 {
+		t.Fatalf("stats report %d panics and %d workers", stats.Panics, stats.Workers)
+	}
+}
+
+func TestRoutinesPoolPanicCompletesFuture(t *testing.T) {
+	pool := NewRoutinesPool(1)
+	pool.SetPanicHandler(func(e *PanicError) {})
+	future := pool.SubmitWithResult(func() (interface{}, error) {
+		panic("future")
+	})
+	_, e := future.Get()
+	if panicError, ok := e.(*PanicError); !ok || panicError.Value != "future" {
+		t.Fatalf("Get returned %v", e)
+	}
+	if _, e := pool.InvokeAny([]Callable{func() (interface{}, error) { panic(1) }}); e == nil {
+		t.Fatal("InvokeAny of a panicking task succeeded")
+	}
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestRoutinesPoolPanicsOfSurplusWorkers(t *testing.T) {
+	// Tasks handed directly to new workers panic before ever reaching the queue
+	pool := NewDynamicRoutinesPool(0, 4, 0, time.Millisecond)
+	pool.SetPanicHandler(func(e *PanicError) {})
+	for i := 0; i < 50; i++ {
+		pool.Submit(panicTask)
+	}
+	closeWithin(t, pool, 10*time.Second)
+	if count := pool.WorkerCount(); count != 0 {
+		t.Fatalf("%d workers left after Close", count)
+	}
+}
+
+func TestRoutinesPoolDefaultPanicHandler(t *testing.T) {
+	pool := NewRoutinesPool(1)
+	pool.SetPanicHandler(func(e *PanicError) {})
+	// nil restores the default handler, which logs
+	pool.SetPanicHandler(nil)
+	pool.Submit(panicTask)
+	closeWithin(t, pool, 10*time.Second)
+}
+
+// eventually fails the test when condition has not held within timeout
+func eventually(t *testing.T, timeout time.Duration, condition func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// occupy submits n tasks blocking until release is closed and waits until they all run
+func occupy(t *testing.T, pool *RoutinesPool, n int, release chan struct{}) {
+	t.Helper()
+	var started sync.WaitGroup
+	started.Add(n)
+	for i := 0; i < n; i++ {
+		if e := pool.Submit(func() {
+			started.Done()
+			<-release
+		}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	started.Wait()
+}
+
+// maxConcurrency runs n tasks on pool and returns how many of them ran at the same time at most
+func maxConcurrency(t *testing.T, pool *RoutinesPool, n int) int32 {
+	var running, peak int32
+	var group sync.WaitGroup
+	group.Add(n)
+	for i := 0; i < n; i++ {
+		if e := pool.Submit(func() {
+			defer group.Done()
+			current := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	group.Wait()
+	return peak
+}
+
+func TestRoutinesPoolKeepAliveRetiresSurplusWorkers(t *testing.T) {
+	pool := NewDynamicRoutinesPool(1, 4, 1, 20*time.Millisecond)
+	// Five blocking tasks fill the queue and every worker the pool may start
+	grow := func() chan struct{} {
+		release := make(chan struct{})
+		for i := 0; i < 5; i++ {
+			if e := pool.Submit(func() { <-release }); e != nil {
+				t.Fatal(e)
+			}
+		}
+		eventually(t, 5*time.Second, func() bool { return pool.WorkerCount() == 4 },
+			"%d workers with a full queue, want 4", pool.WorkerCount())
+		return release
+	}
+	close(grow())
+	eventually(t, 5*time.Second, func() bool { return pool.WorkerCount() == 1 },
+		"%d workers long after keep-alive, want the core one", pool.WorkerCount())
+
+	// Without keep-alive surplus workers exit as soon as the queue is empty
+	pool.SetKeepAlive(0)
+	close(grow())
+	eventually(t, 5*time.Second, func() bool { return pool.WorkerCount() == 1 },
+		"%d workers without keep-alive, want the core one", pool.WorkerCount())
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestRoutinesPoolResizeUp(t *testing.T) {
+	pool := NewRoutinesPool(2)
+	pool.Resize(5, 5)
+	if count := pool.WorkerCount(); count != 5 {
+		t.Fatalf("%d workers after growing to 5", count)
+	}
+	if peak := maxConcurrency(t, pool, 20); peak != 5 {
+		t.Fatalf("%d tasks ran at once, want 5", peak)
+	}
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestRoutinesPoolResizeDown(t *testing.T) {
+	pool := NewRoutinesPool(5)
+	// Busy workers finish their task before exiting
+	release := make(chan struct{})
+	occupy(t, pool, 5, release)
+	pool.Resize(2, 2)
+	if count := pool.WorkerCount(); count != 5 {
+		t.Fatalf("%d workers while 5 are busy", count)
+	}
+	close(release)
+	eventually(t, 5*time.Second, func() bool { return pool.WorkerCount() == 2 },
+		"%d workers after shrinking to 2", pool.WorkerCount())
+	if peak := maxConcurrency(t, pool, 20); peak > 2 {
+		t.Fatalf("%d tasks ran at once, want at most 2", peak)
+	}
+
+	// Idle workers exit right away
+	pool.Resize(1, 1)
+	eventually(t, 5*time.Second, func() bool { return pool.WorkerCount() == 1 },
+		"%d idle workers after shrinking to 1", pool.WorkerCount())
+	closeWithin(t, pool, 10*time.Second)
+}
+
+func TestRoutinesPoolResizeInvalid(t *testing.T) {
+	pool := NewRoutinesPool(1)
+	defer pool.Close()
+	for _, size := range [][2]int{{-1, 1}, {0, 0}, {3, 2}} {
+		expectPanic(t, "Resize", func() { pool.Resize(size[0], size[1]) })
+	}
+}
+
+func TestShutdownNowSettlesQueuedTasks(t *testing.T) {
+	pool := NewDynamicRoutinesPool(1, 1, 3, 0)
+	release := make(chan struct{})
+	defer close(release)
+	occupy(t, pool, 1, release)
+	future := pool.SubmitWithResult(func() (interface{}, error) { return 1, nil })
+	group, _ := NewErrGroup(context.Background())
+	if e := group.GoPool(pool, func() error { return nil }); e != nil {
+		t.Fatal(e)
+	}
+	if e := pool.Submit(func() {}); e != nil {
+		t.Fatal(e)
+	}
+
+	// Only the plain task is handed back, the others are settled
+	if pending := pool.ShutdownNow(); len(pending) != 1 {
+		t.Fatalf("ShutdownNow returned %d pending tasks, want 1", len(pending))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, e := future.GetContext(ctx); e != ErrPoolClosed {
+		t.Fatalf("future of a dropped task returned %v", e)
+	}
+	waited := make(chan error, 1)
+	go func() {
+		waited <- group.Wait()
+	}()
+	select {
+	case e := <-waited:
+		if e != ErrPoolClosed {
+			t.Fatalf("group of a dropped task returned %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait blocks on a task dropped by ShutdownNow")
+	}
+}
@@ -0,0 +1,214 @@
+package concurrency
+
+import (
+	"sync"
+	"time"
+)
+
+// MissedRunPolicy decides what a periodic task does when a run ends after the next one was due
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip drops the missed runs and waits for the next slot of the original cadence
+	MissedRunSkip MissedRunPolicy = iota
+	// MissedRunFireOnce starts one run right away for all the missed slots,
+	// fixed rate tasks then count their period from that run
+	MissedRunFireOnce
+)
+
+// ScheduledTask is the handle of a task waiting in a ScheduledPool. Runs of the same task never overlap,
+// and a panicking run is reported by the underlying pool without stopping the following ones.
+type ScheduledTask struct {
+	pool      *ScheduledPool
+	task      func()
+	next      func(planned, finished time.Time) time.Time
+	mutex     sync.Mutex
+	timer     *time.Timer
+	planned   time.Time
+	cancelled bool
+	done      chan struct{}
+}
+
+// Cancel stops every future run and reports whether the task was still scheduled, a running task is not interrupted
+func (task *ScheduledTask) Cancel() bool {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	return task.stop()
+}
+
+// Done is closed once the task will never be started again
+func (task *ScheduledTask) Done() <-chan struct{} {
+	return task.done
+}
+
+// NextRun returns when the task is due next, the zero time once it is done
+func (task *ScheduledTask) NextRun() time.Time {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	if task.cancelled {
+		return time.Time{}
+	}
+	return task.planned
+}
+
+// stop must be called with mutex held
+func (task *ScheduledTask) stop() bool {
+	if task.cancelled {
+		return false
+	}
+	task.cancelled = true
+	if task.timer != nil {
+		task.timer.Stop()
+	}
+	close(task.done)
+	task.pool.forget(task)
+	return true
+}
+
+// arm must be called with mutex held
+func (task *ScheduledTask) arm(at time.Time) {
+	if at.IsZero() {
+		task.stop()
+		return
+	}
+	task.planned = at
+	task.timer = time.AfterFunc(time.Until(at), task.fire)
+}
+
+func (task *ScheduledTask) fire() {
+	task.mutex.Lock()
+	if task.cancelled {
+		task.mutex.Unlock()
+		return
+	}
+	task.mutex.Unlock()
+	// A run discarded by the rejection policy is skipped, the next one is still planned.
+	// One dropped by ShutdownNow stops the task.
+	e := task.pool.pool.submit(nil, &poolTask{run: task.run, discarded: task.reschedule}, submitWait)
+	if e != nil {
+		task.Cancel()
+	}
+}
+
+func (task *ScheduledTask) run() {
+	defer task.reschedule(nil)
+	task.task()
+}
+
+// reschedule plans the next run of a periodic task and stops a one-shot one,
+// or one whose pool was shut down
+func (task *ScheduledTask) reschedule(e error) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	if task.cancelled {
+		return
+	}
+	if task.next == nil || e == ErrPoolClosed {
+		task.stop()
+		return
+	}
+	task.arm(task.next(task.planned, time.Now()))
+}
+
+// ScheduledPool runs delayed and periodic tasks on a RoutinesPool, timers only hand the task over to the pool
+type ScheduledPool struct {
+	pool   *RoutinesPool
+	mutex  sync.Mutex
+	tasks  map[*ScheduledTask]struct{}
+	closed bool
+}
+
+func NewScheduledPool(pool *RoutinesPool) *ScheduledPool {
+	return &ScheduledPool{pool: pool, tasks: make(map[*ScheduledTask]struct{})}
+}
+
+func (pool *ScheduledPool) schedule(task func(), at time.Time, next func(planned, finished time.Time) time.Time) *ScheduledTask {
+	scheduled := &ScheduledTask{pool: pool, task: task, next: next, done: make(chan struct{})}
+	scheduled.mutex.Lock()
+	defer scheduled.mutex.Unlock()
+	pool.mutex.Lock()
+	closed := pool.closed
+	if !closed {
+		pool.tasks[scheduled] = struct{}{}
+	}
+	pool.mutex.Unlock()
+	if closed {
+		scheduled.cancelled = true
+		close(scheduled.done)
+		return scheduled
+	}
+	scheduled.arm(at)
+	return scheduled
+}
+
+func (pool *ScheduledPool) forget(task *ScheduledTask) {
+	pool.mutex.Lock()
+	delete(pool.tasks, task)
+	pool.mutex.Unlock()
+}
+
+// Schedule runs task once after delay
+func (pool *ScheduledPool) Schedule(task func(), delay time.Duration) *ScheduledTask {
+	return pool.schedule(task, time.Now().Add(delay), nil)
+}
+
+// ScheduleAtFixedRate runs task every period, counted from the planned start of the previous run
+func (pool *ScheduledPool) ScheduleAtFixedRate(task func(), initialDelay, period time.Duration, policy MissedRunPolicy) *ScheduledTask {
+	if period <= 0 {
+		panic("concurrency: non-positive period")
+	}
+	return pool.schedule(task, time.Now().Add(initialDelay), func(planned, finished time.Time) time.Time {
+		next := planned.Add(period)
+		if next.After(finished) {
+			return next
+		}
+		if policy == MissedRunFireOnce {
+			return finished
+		}
+		missed := finished.Sub(next)/period + 1
+		return next.Add(missed * period)
+	})
+}
+
+// ScheduleWithFixedDelay runs task again delay after the previous run has finished
+func (pool *ScheduledPool) ScheduleWithFixedDelay(task func(), initialDelay, delay time.Duration) *ScheduledTask {
+	if delay <= 0 {
+		panic("concurrency: non-positive delay")
+	}
+	return pool.schedule(task, time.Now().Add(initialDelay), func(planned, finished time.Time) time.Time {
+		return finished.Add(delay)
+	})
+}
+
+// ScheduleCron runs task at every minute matching expression, in local time
+func (pool *ScheduledPool) ScheduleCron(expression string, task func(), policy MissedRunPolicy) (*ScheduledTask, error) {
+	schedule, e := ParseCron(expression)
+	if e != nil {
+		return nil, e
+	}
+	return pool.schedule(task, schedule.Next(time.Now()), func(planned, finished time.Time) time.Time {
+		next := schedule.Next(planned)
+		if next.After(finished) || next.IsZero() {
+			return next
+		}
+		if policy == MissedRunFireOnce {
+			return finished
+		}
+		return schedule.Next(finished)
+	}), nil
+}
+
+// Close cancels every scheduled task, runs already handed to the pool still complete.
+// The underlying pool is left open.
+func (pool *ScheduledPool) Close() {
+	pool.mutex.Lock()
+	pool.closed = true
+	tasks := make([]*ScheduledTask, 0, len(pool.tasks))
+	for task := range pool.tasks {
+		tasks = append(tasks, task)
+	}
+	pool.mutex.Unlock()
+	for i := range tasks {
+		tasks[i].Cancel()
+	}
+}
@@ -0,0 +1,116 @@
+package concurrency
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// doneWithin fails the test when task is still scheduled after timeout
+func doneWithin(t *testing.T, task *ScheduledTask, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-task.Done():
+	case <-time.After(timeout):
+		t.Fatal("scheduled task is still running")
+	}
+}
+
+// countRuns schedules a task counting its runs every period
+func countRuns(pool *ScheduledPool, period time.Duration) (*ScheduledTask, *int32) {
+	var runs int32
+	task := pool.ScheduleAtFixedRate(func() { atomic.AddInt32(&runs, 1) }, 0, period, MissedRunSkip)
+	return task, &runs
+}
+
+// stillStopped fails the test when runs changes during a few periods
+func stillStopped(t *testing.T, runs *int32, period time.Duration) {
+	t.Helper()
+	before := atomic.LoadInt32(runs)
+	time.Sleep(5 * period)
+	if after := atomic.LoadInt32(runs); after != before {
+		t.Fatalf("%d runs after the task stopped", after-before)
+	}
+}
+
+func TestScheduledPoolRunsOnce(t *testing.T) {
+	pool := NewScheduledPool(NewRoutinesPool(1))
+	defer pool.pool.Close()
+	var runs int32
+	task := pool.Schedule(func() { atomic.AddInt32(&runs, 1) }, 10*time.Millisecond)
+	doneWithin(t, task, 5*time.Second)
+	if runs != 1 {
+		t.Fatalf("one-shot task ran %d times", runs)
+	}
+	if !task.NextRun().IsZero() || task.Cancel() {
+		t.Fatal("finished task is still scheduled")
+	}
+}
+
+func TestScheduledPoolCancel(t *testing.T) {
+	pool := NewScheduledPool(NewRoutinesPool(1))
+	defer pool.pool.Close()
+	task, runs := countRuns(pool, 5*time.Millisecond)
+	eventually(t, 5*time.Second, func() bool { return atomic.LoadInt32(runs) >= 3 }, "periodic task does not run")
+	if !task.Cancel() {
+		t.Fatal("Cancel of a scheduled task returned false")
+	}
+	doneWithin(t, task, time.Second)
+	// A run handed to the pool before Cancel may still complete
+	time.Sleep(5 * time.Millisecond)
+	stillStopped(t, runs, 5*time.Millisecond)
+}
+
+func TestScheduledPoolClose(t *testing.T) {
+	pool := NewScheduledPool(NewRoutinesPool(2))
+	defer pool.pool.Close()
+	first, firstRuns := countRuns(pool, 5*time.Millisecond)
+	second := pool.ScheduleWithFixedDelay(func() {}, time.Hour, time.Hour)
+	eventually(t, 5*time.Second, func() bool { return atomic.LoadInt32(firstRuns) >= 2 }, "periodic task does not run")
+	pool.Close()
+	doneWithin(t, first, time.Second)
+	doneWithin(t, second, time.Second)
+	time.Sleep(5 * time.Millisecond)
+	stillStopped(t, firstRuns, 5*time.Millisecond)
+
+	// Tasks scheduled after Close never run
+	late, lateRuns := countRuns(pool, time.Millisecond)
+	doneWithin(t, late, time.Second)
+	stillStopped(t, lateRuns, time.Millisecond)
+	if atomic.LoadInt32(lateRuns) != 0 {
+		t.Fatalf("task scheduled after Close ran %d times", *lateRuns)
+	}
+}
+
+func TestScheduledPoolStopsAfterShutdown(t *testing.T) {
+	routines := NewRoutinesPool(1)
+	pool := NewScheduledPool(routines)
+	task, runs := countRuns(pool, 5*time.Millisecond)
+	eventually(t, 5*time.Second, func() bool { return atomic.LoadInt32(runs) >= 2 }, "periodic task does not run")
+	if e := routines.Shutdown(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+	// The next run is refused by the closed pool, which cancels the task
+	doneWithin(t, task, 5*time.Second)
+	stillStopped(t, runs, 5*time.Millisecond)
+}
+
+func TestScheduledPoolFixedDelayDoesNotOverlap(t *testing.T) {
+	pool := NewScheduledPool(NewRoutinesPool(4))
+	defer pool.pool.Close()
+	var running, overlaps, runs int32
+	task := pool.ScheduleWithFixedDelay(func() {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(3 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&runs, 1)
+	}, 0, time.Millisecond)
+	eventually(t, 5*time.Second, func() bool { return atomic.LoadInt32(&runs) >= 5 }, "periodic task does not run")
+	task.Cancel()
+	if overlaps := atomic.LoadInt32(&overlaps); overlaps != 0 {
+		t.Fatalf("%d runs overlapped", overlaps)
+	}
+}
@@ -0,0 +1,94 @@
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrSemaphoreWeight = errors.New("concurrency: weight exceeds semaphore size")
+	ErrNegativeWeight  = errors.New("concurrency: negative semaphore weight")
+)
+
+// Semaphore is a weighted counting semaphore. Waiters are served in FIFO order,
+// so a large request is not starved by a stream of small ones.
+type Semaphore struct {
+	size      int
+	acquired  int
+	waiters   *list.List
+	condition *sync.Cond
+}
+
+func NewSemaphore(size int) *Semaphore {
+	return &Semaphore{size, 0, list.New(), sync.NewCond(new(sync.Mutex))}
+}
+
+func (semaphore *Semaphore) Acquire(weight int) error {
+	return semaphore.AcquireContext(context.Background(), weight)
+}
+
+// AcquireContext blocks until weight is available or ctx is done, nothing is acquired on failure
+func (semaphore *Semaphore) AcquireContext(ctx context.Context, weight int) error {
+	if weight < 0 {
+		return ErrNegativeWeight
+	}
+	if weight > semaphore.size {
+		return ErrSemaphoreWeight
+	}
+	semaphore.condition.L.Lock()
+	defer semaphore.condition.L.Unlock()
+	if semaphore.waiters.Len() == 0 && semaphore.size-semaphore.acquired >= weight {
+		semaphore.acquired += weight
+		return nil
+	}
+
+	element := semaphore.waiters.PushBack(weight)
+	e := waitCondition(ctx, semaphore.condition, func() bool {
+		return semaphore.waiters.Front() == element && semaphore.size-semaphore.acquired >= weight
+	})
+	semaphore.waiters.Remove(element)
+	// Whoever is at the front now may be able to proceed
+	semaphore.condition.Broadcast()
+	if e != nil {
+		return e
+	}
+	semaphore.acquired += weight
+	return nil
+}
+
+// TryAcquire takes weight only when it is available right now and nobody is queued before,
+// a negative weight panics
+func (semaphore *Semaphore) TryAcquire(weight int) bool {
+	if weight < 0 {
+		panic(ErrNegativeWeight)
+	}
+	semaphore.condition.L.Lock()
+	defer semaphore.condition.L.Unlock()
+	if semaphore.waiters.Len() == 0 && semaphore.size-semaphore.acquired >= weight {
+		semaphore.acquired += weight
+		return true
+	}
+	return false
+}
+
+func (semaphore *Semaphore) Release(weight int) {
+	if weight < 0 {
+		panic(ErrNegativeWeight)
+	}
+	semaphore.condition.L.Lock()
+	if semaphore.acquired < weight {
+		semaphore.condition.L.Unlock()
+		panic("concurrency: Semaphore released more than acquired")
+	}
+	semaphore.acquired -= weight
+	semaphore.condition.Broadcast()
+	semaphore.condition.L.Unlock()
+}
+
+func (semaphore *Semaphore) Available() int {
+	semaphore.condition.L.Lock()
+	defer semaphore.condition.L.Unlock()
+	return semaphore.size - semaphore.acquired
+}
@@ -0,0 +1,100 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", name)
+		}
+	}()
+	f()
+}
+
+func TestSemaphoreRejectsNegativeWeights(t *testing.T) {
+	semaphore := NewSemaphore(2)
+	if e := semaphore.Acquire(-5); e != ErrNegativeWeight {
+		t.Fatalf("Acquire(-5) returned %v", e)
+	}
+	if e := semaphore.AcquireContext(context.Background(), -1); e != ErrNegativeWeight {
+		t.Fatalf("AcquireContext(-1) returned %v", e)
+	}
+	expectPanic(t, "TryAcquire(-1)", func() { semaphore.TryAcquire(-1) })
+	expectPanic(t, "Release(-1)", func() { semaphore.Release(-1) })
+	if semaphore.Available() != 2 {
+		t.Fatalf("Available is %d after negative weights", semaphore.Available())
+	}
+}
+
+func TestSemaphoreWeights(t *testing.T) {
+	semaphore := NewSemaphore(3)
+	if e := semaphore.Acquire(4); e != ErrSemaphoreWeight {
+		t.Fatalf("Acquire(4) returned %v", e)
+	}
+	if !semaphore.TryAcquire(2) || semaphore.TryAcquire(2) || !semaphore.TryAcquire(1) {
+		t.Fatal("TryAcquire ignored the available weight")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if e := semaphore.AcquireContext(ctx, 1); e != context.DeadlineExceeded {
+		t.Fatalf("AcquireContext on a full semaphore returned %v", e)
+	}
+	semaphore.Release(3)
+	if semaphore.Available() != 3 {
+		t.Fatalf("Available is %d", semaphore.Available())
+	}
+	expectPanic(t, "Release(1) of nothing", func() { semaphore.Release(1) })
+}
+
+func TestSemaphoreServesWaitersInOrder(t *testing.T) {
+	semaphore := NewSemaphore(4)
+	semaphore.Acquire(4)
+	order := make(chan int, 2)
+	var group sync.WaitGroup
+	group.Add(1)
+	go func() {
+		defer group.Done()
+		semaphore.Acquire(3)
+		order <- 3
+	}()
+	// Let the large request queue up before the small one
+	for semaphore.queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	group.Add(1)
+	go func() {
+		defer group.Done()
+		semaphore.Acquire(1)
+		order <- 1
+	}()
+	for semaphore.queued() == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// One unit would do for the small request, but the large one is queued before it
+	semaphore.Release(1)
+	if semaphore.TryAcquire(1) {
+		t.Fatal("TryAcquire jumped the queue")
+	}
+	semaphore.Release(2)
+	if first := <-order; first != 3 {
+		t.Fatalf("weight %d was served first", first)
+	}
+	semaphore.Release(1)
+	group.Wait()
+	if second := <-order; second != 1 {
+		t.Fatalf("weight %d was served second", second)
+	}
+}
+
+// queued counts the waiters, for tests
+func (semaphore *Semaphore) queued() int {
+	semaphore.condition.L.Lock()
+	defer semaphore.condition.L.Unlock()
+	return semaphore.waiters.Len()
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type keyedEntry struct {
+	limiter  Limiter
+	lastUsed time.Time
+}
+
+// KeyedLimiter keeps one limiter per key, like per host or per client address.
+// Limiters are created on first use and dropped after staying unused for idle.
+type KeyedLimiter struct {
+	mutex     sync.Mutex
+	factory   func() Limiter
+	idle      time.Duration
+	limiters  map[string]*keyedEntry
+	lastSweep time.Time
+	clock     Clock
+}
+
+// NewKeyedLimiter creates limiters with factory, a non-positive idle keeps them forever
+func NewKeyedLimiter(factory func() Limiter, idle time.Duration) *KeyedLimiter {
+	return &KeyedLimiter{
+		factory:   factory,
+		idle:      idle,
+		limiters:  make(map[string]*keyedEntry),
+		lastSweep: time.Now(),
+		clock:     SystemClock,
+	}
+}
+
+// SetClock replaces the system clock used for idle limiters, it must be called before the first Get
+func (keyed *KeyedLimiter) SetClock(clock Clock) {
+	keyed.mutex.Lock()
+	keyed.clock = clock
+	keyed.lastSweep = clock.Now()
+	keyed.mutex.Unlock()
+}
+
+// Get returns the limiter of key, creating it when needed
+func (keyed *KeyedLimiter) Get(key string) Limiter {
+	keyed.mutex.Lock()
+	defer keyed.mutex.Unlock()
+	now := keyed.clock.Now()
+	// Sweeping at most once per idle period keeps Get cheap
+	if keyed.idle > 0 && now.Sub(keyed.lastSweep) >= keyed.idle {
+		for k, entry := range keyed.limiters {
+			if now.Sub(entry.lastUsed) >= keyed.idle {
+				delete(keyed.limiters, k)
+			}
+		}
+		keyed.lastSweep = now
+	}
+	entry, exist := keyed.limiters[key]
+	if !exist {
+		entry = &keyedEntry{limiter: keyed.factory()}
+		keyed.limiters[key] = entry
+	}
+	entry.lastUsed = now
+	return entry.limiter
+}
+
+func (keyed *KeyedLimiter) Allow(key string) bool {
+	return keyed.Get(key).Allow()
+}
+
+func (keyed *KeyedLimiter) Reserve(key string) *Reservation {
+	return keyed.Get(key).Reserve()
+}
+
+func (keyed *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	return keyed.Get(key).Wait(ctx)
+}
+
+// Remove drops the limiter of key, the next use starts afresh
+func (keyed *KeyedLimiter) Remove(key string) {
+	keyed.mutex.Lock()
+	delete(keyed.limiters, key)
+	keyed.mutex.Unlock()
+}
+
+func (keyed *KeyedLimiter) Len() int {
+	keyed.mutex.Lock()
+	defer keyed.mutex.Unlock()
+	return len(keyed.limiters)
+}
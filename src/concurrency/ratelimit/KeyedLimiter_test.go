@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiterDropsIdleLimiters(t *testing.T) {
+	clock := newManualClock()
+	keyed := NewKeyedLimiter(func() Limiter {
+		bucket := NewTokenBucket(1, 1)
+		bucket.SetClock(clock)
+		return bucket
+	}, time.Minute)
+	keyed.SetClock(clock)
+	if !keyed.Allow("a") || !keyed.Allow("b") || keyed.Allow("a") {
+		t.Fatal("keys do not have limiters of their own")
+	}
+	clock.Advance(30 * time.Second)
+	keyed.Get("b")
+	clock.Advance(30 * time.Second)
+	// The sweep drops a but keeps b, used within the last minute
+	keyed.Get("c")
+	if keyed.Len() != 2 {
+		t.Fatalf("%d limiters left, want b and c", keyed.Len())
+	}
+	keyed.Remove("b")
+	if keyed.Len() != 1 {
+		t.Fatalf("%d limiters left after Remove, want c", keyed.Len())
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// infinity is the longest time.Duration, the delay of a reservation which is not OK
+const infinity = time.Duration(1<<63 - 1)
+
+var ErrExceedsDeadline = errors.New("ratelimit: wait would exceed the context deadline")
+
+// Limiter decides when events may happen, implementations are safe for concurrent use
+type Limiter interface {
+	// Allow reports whether one event may happen now, and consumes it when it may
+	Allow() bool
+	// Reserve books one event and tells how long to wait before it may happen
+	Reserve() *Reservation
+	// Wait blocks until one event may happen or ctx is done
+	Wait(ctx context.Context) error
+}
+
+// Clock tells the limiters what time it is, tests replace SystemClock with one they move by hand
+type Clock interface {
+	Now() time.Time
+	// NewTimer is like time.NewTimer, it returns the channel and the Stop function of the timer
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// SystemClock is the clock of every limiter unless SetClock replaced it
+var SystemClock Clock = systemClock{}
+
+// Reservation is an event booked by Reserve
+type Reservation struct {
+	ok        bool
+	timeToAct time.Time
+	clock     Clock
+	cancel    func(timeToAct time.Time)
+}
+
+// OK is false when the event can never happen, like when it does not fit the burst
+func (reservation *Reservation) OK() bool {
+	return reservation.ok
+}
+
+// Delay is how long to wait before acting on the reservation
+func (reservation *Reservation) Delay() time.Duration {
+	if !reservation.ok {
+		return infinity
+	}
+	return reservation.DelayFrom(reservation.clock.Now())
+}
+
+func (reservation *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !reservation.ok {
+		return infinity
+	}
+	if delay := reservation.timeToAct.Sub(now); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// Cancel gives the event back to the limiter when it has not become due yet
+func (reservation *Reservation) Cancel() {
+	if !reservation.ok || reservation.cancel == nil {
+		return
+	}
+	cancel := reservation.cancel
+	reservation.cancel = nil
+	cancel(reservation.timeToAct)
+}
+
+// reserver books an event due no later than now+maxWait, or returns a reservation which is not OK
+type reserver interface {
+	reserve(now time.Time, maxWait time.Duration) *Reservation
+}
+
+func wait(ctx context.Context, clock Clock, limiter reserver) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	now := clock.Now()
+	maxWait := infinity
+	if deadline, exist := ctx.Deadline(); exist {
+		maxWait = deadline.Sub(now)
+	}
+	reservation := limiter.reserve(now, maxWait)
+	if !reservation.ok {
+		return ErrExceedsDeadline
+	}
+	delay := reservation.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	timer, stop := clock.NewTimer(delay)
+	defer stop()
+	select {
+	case <-timer:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type manualTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// manualClock only moves on Advance, which fires the timers falling due.
+// It starts at the real time, so that context deadlines mean the same on both.
+type manualClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers map[*manualTimer]struct{}
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.Now(), timers: make(map[*manualTimer]struct{})}
+}
+
+func (clock *manualClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *manualClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	timer := &manualTimer{at: clock.now.Add(d), c: make(chan time.Time, 1)}
+	clock.timers[timer] = struct{}{}
+	return timer.c, func() bool {
+		clock.mutex.Lock()
+		defer clock.mutex.Unlock()
+		_, pending := clock.timers[timer]
+		delete(clock.timers, timer)
+		return pending
+	}
+}
+
+func (clock *manualClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+	for timer := range clock.timers {
+		if !timer.at.After(clock.now) {
+			timer.c <- clock.now
+			delete(clock.timers, timer)
+		}
+	}
+}
+
+// awaitTimers waits until n timers are pending, that is until n goroutines block in Wait
+func (clock *manualClock) awaitTimers(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		clock.mutex.Lock()
+		pending := len(clock.timers)
+		clock.mutex.Unlock()
+		if pending == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want %d", pending, n)
+		}
+	}
+}
+
+// clockedLimiter is a limiter whose clock a test controls
+type clockedLimiter interface {
+	Limiter
+	SetClock(clock Clock)
+}
+
+// limiters returns a limiter of each kind letting one event happen per second
+func limiters() map[string]clockedLimiter {
+	return map[string]clockedLimiter{
+		"TokenBucket":   NewTokenBucket(1, 1),
+		"SlidingWindow": NewSlidingWindow(1, time.Second),
+	}
+}
+
+func waitAsync(limiter Limiter, ctx context.Context) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- limiter.Wait(ctx)
+	}()
+	return result
+}
+
+func receive(t *testing.T, result chan error) error {
+	t.Helper()
+	select {
+	case e := <-result:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return")
+		return nil
+	}
+}
+
+func TestWaitReturnsOnceDue(t *testing.T) {
+	for name, limiter := range limiters() {
+		t.Run(name, func(t *testing.T) {
+			clock := newManualClock()
+			limiter.SetClock(clock)
+			if e := limiter.Wait(context.Background()); e != nil {
+				t.Fatalf("first Wait returned %v", e)
+			}
+			result := waitAsync(limiter, context.Background())
+			clock.awaitTimers(t, 1)
+			clock.Advance(999 * time.Millisecond)
+			select {
+			case e := <-result:
+				t.Fatalf("Wait returned %v before the event was due", e)
+			default:
+			}
+			clock.Advance(time.Millisecond)
+			if e := receive(t, result); e != nil {
+				t.Fatalf("Wait returned %v", e)
+			}
+		})
+	}
+}
+
+func TestWaitCancelledByContext(t *testing.T) {
+	for name, limiter := range limiters() {
+		t.Run(name, func(t *testing.T) {
+			clock := newManualClock()
+			limiter.SetClock(clock)
+			if !limiter.Allow() {
+				t.Fatal("the first event was not allowed")
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			result := waitAsync(limiter, ctx)
+			clock.awaitTimers(t, 1)
+			cancel()
+			if e := receive(t, result); e != context.Canceled {
+				t.Fatalf("cancelled Wait returned %v", e)
+			}
+			clock.awaitTimers(t, 0)
+			// The cancelled event was given back, so one second later a single event fits again
+			clock.Advance(time.Second)
+			if !limiter.Allow() {
+				t.Fatal("the cancelled event still holds its place")
+			}
+			if limiter.Allow() {
+				t.Fatal("the burst was exceeded")
+			}
+			if e := limiter.Wait(ctx); e != context.Canceled {
+				t.Fatalf("Wait with a done context returned %v", e)
+			}
+		})
+	}
+}
+
+func TestWaitExceedsDeadline(t *testing.T) {
+	for name, limiter := range limiters() {
+		t.Run(name, func(t *testing.T) {
+			clock := newManualClock()
+			limiter.SetClock(clock)
+			limiter.Allow()
+			ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+			defer cancel()
+			if e := limiter.Wait(ctx); e != ErrExceedsDeadline {
+				t.Fatalf("got %v, want ErrExceedsDeadline", e)
+			}
+			// Nothing was booked by the failed Wait
+			clock.Advance(time.Second)
+			if !limiter.Allow() {
+				t.Fatal("the failed Wait kept a reservation")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SlidingWindow lets at most limit events happen within any window, so limit is also its burst.
+// It keeps the time of every event in the last window, reserved events included.
+type SlidingWindow struct {
+	mutex  sync.Mutex
+	limit  int
+	window time.Duration
+	// Sorted, reserved events may lie in the future
+	log   []time.Time
+	clock Clock
+}
+
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	if limit < 1 {
+		panic("ratelimit: limit must be positive")
+	}
+	if window <= 0 {
+		panic("ratelimit: non-positive window")
+	}
+	return &SlidingWindow{limit: limit, window: window, log: make([]time.Time, 0, limit), clock: SystemClock}
+}
+
+// SetClock replaces the system clock, it must be called before the limiter is used
+func (limiter *SlidingWindow) SetClock(clock Clock) {
+	limiter.mutex.Lock()
+	limiter.clock = clock
+	limiter.mutex.Unlock()
+}
+
+// prune must be called with mutex held
+func (limiter *SlidingWindow) prune(now time.Time) {
+	expired := 0
+	for expired < len(limiter.log) && !limiter.log[expired].After(now.Add(-limiter.window)) {
+		expired++
+	}
+	if expired > 0 {
+		limiter.log = append(limiter.log[:0], limiter.log[expired:]...)
+	}
+}
+
+func (limiter *SlidingWindow) reserve(now time.Time, maxWait time.Duration) *Reservation {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.prune(now)
+	// Any limit consecutive events span less than one window, so the new one
+	// has to wait until the limit-th latest event leaves the window
+	timeToAct := now
+	if n := len(limiter.log); n >= limiter.limit {
+		timeToAct = limiter.log[n-limiter.limit].Add(limiter.window)
+	}
+	// A cancelled reservation may have left a gap, the log still has to stay sorted
+	if n := len(limiter.log); n > 0 && timeToAct.Before(limiter.log[n-1]) {
+		timeToAct = limiter.log[n-1]
+	}
+	if timeToAct.Sub(now) > maxWait {
+		return &Reservation{}
+	}
+	limiter.log = append(limiter.log, timeToAct)
+	return &Reservation{ok: true, timeToAct: timeToAct, clock: limiter.clock, cancel: limiter.restore}
+}
+
+func (limiter *SlidingWindow) restore(timeToAct time.Time) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if !timeToAct.After(limiter.clock.Now()) {
+		return
+	}
+	for i := len(limiter.log) - 1; i >= 0; i-- {
+		if limiter.log[i].Equal(timeToAct) {
+			limiter.log = append(limiter.log[:i], limiter.log[i+1:]...)
+			return
+		}
+	}
+}
+
+func (limiter *SlidingWindow) Allow() bool {
+	return limiter.reserve(limiter.clock.Now(), 0).ok
+}
+
+func (limiter *SlidingWindow) Reserve() *Reservation {
+	return limiter.reserve(limiter.clock.Now(), infinity)
+}
+
+func (limiter *SlidingWindow) Wait(ctx context.Context) error {
+	return wait(ctx, limiter.clock, limiter)
+}
+
+// Len returns the number of events in the current window, pending reservations included
+func (limiter *SlidingWindow) Len() int {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.prune(limiter.clock.Now())
+	return len(limiter.log)
+}
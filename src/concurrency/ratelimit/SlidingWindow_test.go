@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowRollover(t *testing.T) {
+	clock := newManualClock()
+	limiter := NewSlidingWindow(2, time.Second)
+	limiter.SetClock(clock)
+	if !limiter.Allow() {
+		t.Fatal("the first event was not allowed")
+	}
+	clock.Advance(600 * time.Millisecond)
+	if n := allowed(limiter); n != 1 {
+		t.Fatalf("allowed %d events for the rest of the window, want 1", n)
+	}
+	// The first event leaves the window exactly one window after it happened
+	clock.Advance(399 * time.Millisecond)
+	if limiter.Allow() {
+		t.Fatal("an event was allowed before the first one left the window")
+	}
+	clock.Advance(time.Millisecond)
+	if limiter.Len() != 1 {
+		t.Fatalf("%d events in the window, want the second one", limiter.Len())
+	}
+	if n := allowed(limiter); n != 1 {
+		t.Fatalf("allowed %d events after the rollover, want 1", n)
+	}
+	clock.Advance(2 * time.Second)
+	if limiter.Len() != 0 {
+		t.Fatalf("%d events left after two windows", limiter.Len())
+	}
+	if n := allowed(limiter); n != 2 {
+		t.Fatalf("an empty window allowed %d events, want 2", n)
+	}
+}
+
+func TestSlidingWindowReserve(t *testing.T) {
+	clock := newManualClock()
+	limiter := NewSlidingWindow(2, time.Second)
+	limiter.SetClock(clock)
+	limiter.Allow()
+	clock.Advance(100 * time.Millisecond)
+	// Every event waits for the one two places before it to leave the window
+	for i, expected := range []time.Duration{0, 900 * time.Millisecond, time.Second, 1900 * time.Millisecond} {
+		if delay := limiter.Reserve().Delay(); delay != expected {
+			t.Fatalf("reservation %d waits %v, want %v", i, delay, expected)
+		}
+	}
+	if limiter.Len() != 5 {
+		t.Fatalf("%d events logged, want 5 with the reservations", limiter.Len())
+	}
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket refills rate tokens per second up to burst, every event takes one token.
+// Reservations may take the bucket below zero, later events then wait for the refill to catch up.
+type TokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+// NewTokenBucket starts with a full bucket
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		panic("ratelimit: non-positive rate")
+	}
+	if burst < 1 {
+		panic("ratelimit: burst must be positive")
+	}
+	return &TokenBucket{rate: rate, burst: burst, tokens: float64(burst), last: time.Now(), clock: SystemClock}
+}
+
+// SetClock replaces the system clock, it must be called before the bucket is used
+func (bucket *TokenBucket) SetClock(clock Clock) {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	bucket.clock = clock
+	bucket.last = clock.Now()
+}
+
+// advance must be called with mutex held
+func (bucket *TokenBucket) advance(now time.Time) {
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * bucket.rate
+		if bucket.tokens > float64(bucket.burst) {
+			bucket.tokens = float64(bucket.burst)
+		}
+		bucket.last = now
+	}
+}
+
+func (bucket *TokenBucket) reserve(now time.Time, maxWait time.Duration) *Reservation {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	bucket.advance(now)
+	tokens := bucket.tokens - 1
+	var delay time.Duration
+	if tokens < 0 {
+		delay = time.Duration(-tokens / bucket.rate * float64(time.Second))
+	}
+	if delay > maxWait {
+		return &Reservation{}
+	}
+	bucket.tokens = tokens
+	return &Reservation{ok: true, timeToAct: now.Add(delay), clock: bucket.clock, cancel: bucket.restore}
+}
+
+func (bucket *TokenBucket) restore(timeToAct time.Time) {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	now := bucket.clock.Now()
+	if !timeToAct.After(now) {
+		return
+	}
+	bucket.advance(now)
+	bucket.tokens++
+	if bucket.tokens > float64(bucket.burst) {
+		bucket.tokens = float64(bucket.burst)
+	}
+}
+
+func (bucket *TokenBucket) Allow() bool {
+	return bucket.reserve(bucket.clock.Now(), 0).ok
+}
+
+func (bucket *TokenBucket) Reserve() *Reservation {
+	return bucket.reserve(bucket.clock.Now(), infinity)
+}
+
+func (bucket *TokenBucket) Wait(ctx context.Context) error {
+	return wait(ctx, bucket.clock, bucket)
+}
+
+// SetLimit changes the refill rate and the burst, tokens already in the bucket are kept up to the new burst
+func (bucket *TokenBucket) SetLimit(rate float64, burst int) {
+	if rate <= 0 {
+		panic("ratelimit: non-positive rate")
+	}
+	if burst < 1 {
+		panic("ratelimit: burst must be positive")
+	}
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	bucket.advance(bucket.clock.Now())
+	bucket.rate = rate
+	bucket.burst = burst
+	if bucket.tokens > float64(burst) {
+		bucket.tokens = float64(burst)
+	}
+}
+
+// Tokens returns the tokens available now, negative while reservations are pending
+func (bucket *TokenBucket) Tokens() float64 {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	bucket.advance(bucket.clock.Now())
+	return bucket.tokens
+}
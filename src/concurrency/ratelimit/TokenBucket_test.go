@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// allowed counts the events allowed in a row
+func allowed(limiter Limiter) int {
+	n := 0
+	for limiter.Allow() {
+		n++
+	}
+	return n
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	clock := newManualClock()
+	bucket := NewTokenBucket(2, 3)
+	bucket.SetClock(clock)
+	if n := allowed(bucket); n != 3 {
+		t.Fatalf("a full bucket allowed %d events, want its burst of 3", n)
+	}
+	// Two tokens per second, a fraction of a token allows nothing
+	clock.Advance(250 * time.Millisecond)
+	if bucket.Allow() {
+		t.Fatal("half a token allowed an event")
+	}
+	clock.Advance(250 * time.Millisecond)
+	if n := allowed(bucket); n != 1 {
+		t.Fatalf("one token allowed %d events", n)
+	}
+	// The refill stops at the burst
+	clock.Advance(time.Hour)
+	if tokens := bucket.Tokens(); tokens != 3 {
+		t.Fatalf("got %v tokens after an hour, want the burst of 3", tokens)
+	}
+	if n := allowed(bucket); n != 3 {
+		t.Fatalf("a refilled bucket allowed %d events, want 3", n)
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	clock := newManualClock()
+	bucket := NewTokenBucket(2, 1)
+	bucket.SetClock(clock)
+	for i, expected := range []time.Duration{0, 500 * time.Millisecond, time.Second} {
+		if delay := bucket.Reserve().Delay(); delay != expected {
+			t.Fatalf("reservation %d waits %v, want %v", i, delay, expected)
+		}
+	}
+	if tokens := bucket.Tokens(); tokens != -2 {
+		t.Fatalf("got %v tokens, want 2 owed", tokens)
+	}
+	last := bucket.Reserve()
+	last.Cancel()
+	last.Cancel()
+	if tokens := bucket.Tokens(); tokens != -2 {
+		t.Fatalf("got %v tokens after cancelling twice, want 2 owed", tokens)
+	}
+	// A reservation which has become due is not given back
+	due := bucket.Reserve()
+	clock.Advance(2 * time.Second)
+	due.Cancel()
+	if tokens := bucket.Tokens(); tokens != 1 {
+		t.Fatalf("got %v tokens, want 1", tokens)
+	}
+}
+
+func TestTokenBucketSetLimit(t *testing.T) {
+	clock := newManualClock()
+	bucket := NewTokenBucket(1, 4)
+	bucket.SetClock(clock)
+	bucket.SetLimit(4, 2)
+	if n := allowed(bucket); n != 2 {
+		t.Fatalf("lowering the burst kept %d tokens, want 2", n)
+	}
+	clock.Advance(250 * time.Millisecond)
+	if n := allowed(bucket); n != 1 {
+		t.Fatalf("the new rate refilled %d tokens in 250ms, want 1", n)
+	}
+}
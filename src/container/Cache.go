@@ -0,0 +1,257 @@
+package container
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is safe for concurrent use, a zero ttl means entries never expire
+type Cache interface {
+	Get(key interface{}) (interface{}, bool)
+	Put(key, value interface{})
+	PutWithTTL(key, value interface{}, ttl time.Duration)
+	Remove(key interface{}) bool
+	Len() int
+	Purge() int
+}
+
+type cacheEntry struct {
+	key      interface{}
+	value    interface{}
+	expireAt time.Time
+}
+
+func (entry *cacheEntry) expired(now time.Time) bool {
+	return !entry.expireAt.IsZero() && now.After(entry.expireAt)
+}
+
+func (entry *cacheEntry) refresh(value interface{}, ttl time.Duration) {
+	entry.value = value
+	entry.expireAt = time.Time{}
+	if ttl > 0 {
+		entry.expireAt = time.Now().Add(ttl)
+	}
+}
+
+// LRUCache evicts the least recently used entry once capacity is exceeded
+type LRUCache struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[interface{}]*list.Element
+	order    *list.List
+}
+
+func NewLRUCache(capacity int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[interface{}]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (cache *LRUCache) Get(key interface{}) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, exist := cache.entries[key]
+	if !exist {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if entry.expired(time.Now()) {
+		cache.remove(element)
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (cache *LRUCache) Put(key, value interface{}) {
+	cache.PutWithTTL(key, value, cache.ttl)
+}
+
+func (cache *LRUCache) PutWithTTL(key, value interface{}, ttl time.Duration) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, exist := cache.entries[key]; exist {
+		element.Value.(*cacheEntry).refresh(value, ttl)
+		cache.order.MoveToFront(element)
+		return
+	}
+	entry := &cacheEntry{key: key}
+	entry.refresh(value, ttl)
+	cache.entries[key] = cache.order.PushFront(entry)
+	if cache.capacity > 0 && cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+	}
+}
+
+func (cache *LRUCache) Remove(key interface{}) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, exist := cache.entries[key]
+	if exist {
+		cache.remove(element)
+	}
+	return exist
+}
+
+// Len counts expired entries which have not been purged yet
+func (cache *LRUCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.order.Len()
+}
+
+// Purge drops every expired entry and returns how many were dropped
+func (cache *LRUCache) Purge() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	now := time.Now()
+	count := 0
+	for element := cache.order.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*cacheEntry).expired(now) {
+			cache.remove(element)
+			count++
+		}
+		element = next
+	}
+	return count
+}
+
+func (cache *LRUCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*cacheEntry).key)
+}
+
+type lfuBucket struct {
+	frequency int
+	entries   *list.List
+}
+
+type lfuEntry struct {
+	cacheEntry
+	bucket  *list.Element
+	element *list.Element
+}
+
+// LFUCache evicts the least frequently used entry once capacity is exceeded,
+// ties are broken by evicting the least recently used one. Every operation is O(1).
+type LFUCache struct {
+	mutex       sync.Mutex
+	capacity    int
+	ttl         time.Duration
+	entries     map[interface{}]*lfuEntry
+	frequencies *list.List
+}
+
+func NewLFUCache(capacity int, ttl time.Duration) *LFUCache {
+	return &LFUCache{
+		capacity:    capacity,
+		ttl:         ttl,
+		entries:     make(map[interface{}]*lfuEntry),
+		frequencies: list.New(),
+	}
+}
+
+func (cache *LFUCache) Get(key interface{}) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, exist := cache.entries[key]
+	if !exist {
+		return nil, false
+	}
+	if entry.expired(time.Now()) {
+		cache.remove(entry)
+		return nil, false
+	}
+	cache.touch(entry)
+	return entry.value, true
+}
+
+func (cache *LFUCache) Put(key, value interface{}) {
+	cache.PutWithTTL(key, value, cache.ttl)
+}
+
+func (cache *LFUCache) PutWithTTL(key, value interface{}, ttl time.Duration) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if entry, exist := cache.entries[key]; exist {
+		entry.refresh(value, ttl)
+		cache.touch(entry)
+		return
+	}
+	if cache.capacity > 0 && len(cache.entries) >= cache.capacity {
+		bucket := cache.frequencies.Front().Value.(*lfuBucket)
+		cache.remove(bucket.entries.Back().Value.(*lfuEntry))
+	}
+
+	entry := &lfuEntry{cacheEntry: cacheEntry{key: key}}
+	entry.refresh(value, ttl)
+	front := cache.frequencies.Front()
+	if front == nil || front.Value.(*lfuBucket).frequency != 1 {
+		front = cache.frequencies.PushFront(&lfuBucket{1, list.New()})
+	}
+	entry.bucket = front
+	entry.element = front.Value.(*lfuBucket).entries.PushFront(entry)
+	cache.entries[key] = entry
+}
+
+func (cache *LFUCache) Remove(key interface{}) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, exist := cache.entries[key]
+	if exist {
+		cache.remove(entry)
+	}
+	return exist
+}
+
+// Len counts expired entries which have not been purged yet
+func (cache *LFUCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.entries)
+}
+
+// Purge drops every expired entry and returns how many were dropped
+func (cache *LFUCache) Purge() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	now := time.Now()
+	count := 0
+	for _, entry := range cache.entries {
+		if entry.expired(now) {
+			cache.remove(entry)
+			count++
+		}
+	}
+	return count
+}
+
+// touch moves entry into the bucket of the next frequency
+func (cache *LFUCache) touch(entry *lfuEntry) {
+	current := entry.bucket.Value.(*lfuBucket)
+	next := entry.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).frequency != current.frequency+1 {
+		next = cache.frequencies.InsertAfter(&lfuBucket{current.frequency + 1, list.New()}, entry.bucket)
+	}
+	current.entries.Remove(entry.element)
+	if current.entries.Len() == 0 {
+		cache.frequencies.Remove(entry.bucket)
+	}
+	entry.bucket = next
+	entry.element = next.Value.(*lfuBucket).entries.PushFront(entry)
+}
+
+func (cache *LFUCache) remove(entry *lfuEntry) {
+	bucket := entry.bucket.Value.(*lfuBucket)
+	bucket.entries.Remove(entry.element)
+	if bucket.entries.Len() == 0 {
+		cache.frequencies.Remove(entry.bucket)
+	}
+	delete(cache.entries, entry.key)
+}
@@ -0,0 +1,136 @@
+package container
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache(2, 0)
+	cache.Put(1, "one")
+	cache.Put(2, "two")
+	cache.Get(1)
+	cache.Put(3, "three")
+	if _, ok := cache.Get(2); ok {
+		t.Fatal("2 was the least recently used and should have been evicted")
+	}
+	for _, key := range []int{1, 3} {
+		if _, ok := cache.Get(key); !ok {
+			t.Fatalf("%d was evicted", key)
+		}
+	}
+	// Updating an entry counts as using it
+	cache.Put(1, "uno")
+	cache.Put(4, "four")
+	if value, ok := cache.Get(1); !ok || value != "uno" {
+		t.Fatalf("Get(1) = %v, %v", value, ok)
+	}
+	if _, ok := cache.Get(3); ok {
+		t.Fatal("3 should have been evicted")
+	}
+	if !cache.Remove(4) || cache.Remove(4) || cache.Len() != 1 {
+		t.Fatal("Remove did not drop 4 exactly once")
+	}
+}
+
+func TestLFUCacheEvictsLeastFrequentlyUsed(t *testing.T) {
+	cache := NewLFUCache(2, 0)
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Get(1)
+	cache.Get(1)
+	cache.Get(2)
+	cache.Put(3, 3)
+	if _, ok := cache.Get(2); ok {
+		t.Fatal("2 was used less than 1 and should have been evicted")
+	}
+	if _, ok := cache.Get(1); !ok {
+		t.Fatal("1 was evicted")
+	}
+	// 3 and 4 are both used once, the least recent of them goes
+	cache = NewLFUCache(3, 0)
+	cache.Put(1, 1)
+	cache.Get(1)
+	cache.Put(3, 3)
+	cache.Put(4, 4)
+	cache.Put(5, 5)
+	if _, ok := cache.Get(3); ok {
+		t.Fatal("3 should have been evicted before 4")
+	}
+	if cache.Len() != 3 || !cache.Remove(4) || cache.Len() != 2 {
+		t.Fatal("Remove did not drop 4")
+	}
+}
+
+func testCacheExpiry(t *testing.T, cache Cache) {
+	cache.PutWithTTL("short", 1, 10*time.Millisecond)
+	cache.PutWithTTL("long", 2, time.Hour)
+	cache.Put("default", 3)
+	if _, ok := cache.Get("short"); !ok {
+		t.Fatal("short expired too soon")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get("short"); ok {
+		t.Fatal("short did not expire")
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Fatal("long expired")
+	}
+
+	cache.PutWithTTL("purged", 4, 10*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if cache.Len() != 3 {
+		t.Fatalf("Len is %d, expired entries are counted until purged", cache.Len())
+	}
+	if purged := cache.Purge(); purged != 1 || cache.Len() != 2 {
+		t.Fatalf("Purge dropped %d entries, %d left", purged, cache.Len())
+	}
+	// Refreshing an entry resets its deadline
+	cache.PutWithTTL("long", 5, 10*time.Millisecond)
+	cache.PutWithTTL("long", 6, 0)
+	time.Sleep(30 * time.Millisecond)
+	if value, ok := cache.Get("long"); !ok || value != 6 {
+		t.Fatalf("Get(long) = %v, %v", value, ok)
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	testCacheExpiry(t, NewLRUCache(10, 0))
+}
+
+func TestLFUCacheExpiry(t *testing.T) {
+	testCacheExpiry(t, NewLFUCache(10, 0))
+}
+
+func TestCacheDefaultTTL(t *testing.T) {
+	for _, cache := range []Cache{NewLRUCache(10, 10*time.Millisecond), NewLFUCache(10, 10*time.Millisecond)} {
+		cache.Put(1, 1)
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := cache.Get(1); ok {
+			t.Fatalf("%T: entry outlived the default ttl", cache)
+		}
+	}
+}
+
+func benchmarkCache(b *testing.B, cache Cache) {
+	keys := make([]string, 2048)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i*7919%len(keys)]
+		if _, ok := cache.Get(key); !ok {
+			cache.Put(key, i)
+		}
+	}
+}
+
+func BenchmarkLRUCache(b *testing.B) {
+	benchmarkCache(b, NewLRUCache(1024, 0))
+}
+
+func BenchmarkLFUCache(b *testing.B) {
+	benchmarkCache(b, NewLFUCache(1024, 0))
+}
@@ -0,0 +1,91 @@
+package container
+
+// Deque is a double-ended queue over a circular buffer which doubles when full
+type Deque struct {
+	head   int
+	length int
+	items  []interface{}
+}
+
+func NewDeque(capacity int) *Deque {
+	if capacity < 4 {
+		capacity = 4
+	}
+	return &Deque{0, 0, make([]interface{}, capacity)}
+}
+
+func (deque *Deque) Len() int {
+	return deque.length
+}
+
+func (deque *Deque) index(i int) int {
+	return (deque.head + i) % len(deque.items)
+}
+
+func (deque *Deque) grow() {
+	if deque.length < len(deque.items) {
+		return
+	}
+	items := make([]interface{}, len(deque.items)*2)
+	n := copy(items, deque.items[deque.head:])
+	copy(items[n:], deque.items[:deque.head])
+	deque.head = 0
+	deque.items = items
+}
+
+func (deque *Deque) PushBack(item interface{}) {
+	deque.grow()
+	deque.items[deque.index(deque.length)] = item
+	deque.length++
+}
+
+func (deque *Deque) PushFront(item interface{}) {
+	deque.grow()
+	deque.head = (deque.head - 1 + len(deque.items)) % len(deque.items)
+	deque.items[deque.head] = item
+	deque.length++
+}
+
+func (deque *Deque) PopFront() (interface{}, bool) {
+	if deque.length == 0 {
+		return nil, false
+	}
+	item := deque.items[deque.head]
+	deque.items[deque.head] = nil
+	deque.head = deque.index(1)
+	deque.length--
+	return item, true
+}
+
+func (deque *Deque) PopBack() (interface{}, bool) {
+	if deque.length == 0 {
+		return nil, false
+	}
+	i := deque.index(deque.length - 1)
+	item := deque.items[i]
+	deque.items[i] = nil
+	deque.length--
+	return item, true
+}
+
+func (deque *Deque) Front() (interface{}, bool) {
+	if deque.length == 0 {
+		return nil, false
+	}
+	return deque.items[deque.head], true
+}
+
+func (deque *Deque) Back() (interface{}, bool) {
+	if deque.length == 0 {
+		return nil, false
+	}
+	return deque.items[deque.index(deque.length-1)], true
+}
+
+// At returns the i-th item counted from the front, it panics when i is out of range
+func (deque *Deque) At(i int) interface{} {
+	if i < 0 || i >= deque.length {
+		panic("container: deque index out of range")
+	}
+	return deque.items[deque.index(i)]
+}
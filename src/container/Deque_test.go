@@ -0,0 +1,75 @@
+package container
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDequeMatchesSlice(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	deque := NewDeque(0)
+	expected := make([]int, 0)
+	for i := 0; i < 5000; i++ {
+		switch random.Intn(4) {
+		case 0:
+			deque.PushBack(i)
+			expected = append(expected, i)
+		case 1:
+			deque.PushFront(i)
+			expected = append([]int{i}, expected...)
+		case 2:
+			item, ok := deque.PopFront()
+			if ok != (len(expected) > 0) || ok && item.(int) != expected[0] {
+				t.Fatalf("PopFront returned %v, %v, want %v", item, ok, expected)
+			}
+			if ok {
+				expected = expected[1:]
+			}
+		case 3:
+			item, ok := deque.PopBack()
+			if ok != (len(expected) > 0) || ok && item.(int) != expected[len(expected)-1] {
+				t.Fatalf("PopBack returned %v, %v, want %v", item, ok, expected)
+			}
+			if ok {
+				expected = expected[:len(expected)-1]
+			}
+		}
+		if deque.Len() != len(expected) {
+			t.Fatalf("Len is %d, want %d", deque.Len(), len(expected))
+		}
+		for k := range expected {
+			if deque.At(k).(int) != expected[k] {
+				t.Fatalf("At(%d) is %v, want %d", k, deque.At(k), expected[k])
+			}
+		}
+		if front, ok := deque.Front(); ok && front.(int) != expected[0] {
+			t.Fatalf("Front is %v, want %d", front, expected[0])
+		}
+		if back, ok := deque.Back(); ok && back.(int) != expected[len(expected)-1] {
+			t.Fatalf("Back is %v, want %d", back, expected[len(expected)-1])
+		}
+	}
+}
+
+func TestDequeAtOutOfRange(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("At(1) on a deque of one item did not panic")
+		}
+	}()
+	deque := NewDeque(4)
+	deque.PushBack(1)
+	deque.At(1)
+}
+
+func BenchmarkDeque(b *testing.B) {
+	deque := NewDeque(0)
+	for i := 0; i < b.N; i++ {
+		deque.PushBack(i)
+		deque.PushFront(i)
+		if deque.Len() > 1000 {
+			deque.PopFront()
+			deque.PopBack()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package container
+
+// Heap is a d-ary min-heap ordered by less, a larger arity makes Push cheaper and Pop dearer
+type Heap struct {
+	arity int
+	items []interface{}
+	less  func(a, b interface{}) bool
+}
+
+func NewHeap(less func(a, b interface{}) bool) *Heap {
+	return NewDaryHeap(2, less)
+}
+
+func NewDaryHeap(arity int, less func(a, b interface{}) bool) *Heap {
+	if arity < 2 {
+		arity = 2
+	}
+	return &Heap{arity, make([]interface{}, 0), less}
+}
+
+func (heap *Heap) Len() int {
+	return len(heap.items)
+}
+
+func (heap *Heap) Push(item interface{}) {
+	heap.items = append(heap.items, item)
+	heap.up(len(heap.items) - 1)
+}
+
+// Peek returns the smallest item without removing it
+func (heap *Heap) Peek() (interface{}, bool) {
+	if len(heap.items) == 0 {
+		return nil, false
+	}
+	return heap.items[0], true
+}
+
+// Pop removes and returns the smallest item
+func (heap *Heap) Pop() (interface{}, bool) {
+	n := len(heap.items)
+	if n == 0 {
+		return nil, false
+	}
+	top := heap.items[0]
+	heap.items[0] = heap.items[n-1]
+	heap.items[n-1] = nil
+	heap.items = heap.items[:n-1]
+	heap.down(0)
+	return top, true
+}
+
+// PushPop pushes item then pops the smallest, faster than calling both
+func (heap *Heap) PushPop(item interface{}) interface{} {
+	if len(heap.items) == 0 || !heap.less(heap.items[0], item) {
+		return item
+	}
+	top := heap.items[0]
+	heap.items[0] = item
+	heap.down(0)
+	return top
+}
+
+func (heap *Heap) Clear() {
+	heap.items = heap.items[:0]
+}
+
+func (heap *Heap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / heap.arity
+		if !heap.less(heap.items[i], heap.items[parent]) {
+			return
+		}
+		heap.items[i], heap.items[parent] = heap.items[parent], heap.items[i]
+		i = parent
+	}
+}
+
+func (heap *Heap) down(i int) {
+	n := len(heap.items)
+	for {
+		smallest := i
+		first := heap.arity*i + 1
+		for child := first; child < first+heap.arity && child < n; child++ {
+			if heap.less(heap.items[child], heap.items[smallest]) {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		heap.items[i], heap.items[smallest] = heap.items[smallest], heap.items[i]
+		i = smallest
+	}
+}
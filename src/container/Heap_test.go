@@ -0,0 +1,90 @@
+package container
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func intLess(a, b interface{}) bool {
+	return a.(int) < b.(int)
+}
+
+func TestHeapPopsInOrder(t *testing.T) {
+	for _, arity := range []int{0, 2, 3, 4, 8} {
+		h := NewDaryHeap(arity, intLess)
+		values := rand.New(rand.NewSource(1)).Perm(1000)
+		for _, value := range values {
+			h.Push(value % 100)
+		}
+		sort.Ints(values)
+		for i := range values {
+			if top, _ := h.Peek(); top.(int) != i/10 {
+				t.Fatalf("arity %d: peeked %v, want %d", arity, top, i/10)
+			}
+			if item, ok := h.Pop(); !ok || item.(int) != i/10 {
+				t.Fatalf("arity %d: popped %v, want %d", arity, item, i/10)
+			}
+		}
+		if _, ok := h.Pop(); ok || h.Len() != 0 {
+			t.Fatalf("arity %d: heap not empty", arity)
+		}
+	}
+}
+
+func TestHeapPushPop(t *testing.T) {
+	h := NewHeap(intLess)
+	if item := h.PushPop(5); item.(int) != 5 || h.Len() != 0 {
+		t.Fatalf("PushPop on an empty heap returned %v", item)
+	}
+	h.Push(3)
+	h.Push(7)
+	if item := h.PushPop(1); item.(int) != 1 {
+		t.Fatalf("got %v, want 1", item)
+	}
+	if item := h.PushPop(5); item.(int) != 3 {
+		t.Fatalf("got %v, want 3", item)
+	}
+	h.Clear()
+	if _, ok := h.Peek(); ok {
+		t.Fatal("Clear left items")
+	}
+}
+
+type intHeap []int
+
+func (h intHeap) Len() int            { return len(h) }
+func (h intHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func BenchmarkHeap(b *testing.B) {
+	for _, arity := range []int{2, 4} {
+		b.Run("Arity"+strconv.Itoa(arity), func(b *testing.B) {
+			h := NewDaryHeap(arity, intLess)
+			for i := 0; i < b.N; i++ {
+				h.Push(i * 7919 % 1000)
+				if h.Len() > 1000 {
+					h.Pop()
+				}
+			}
+		})
+	}
+	b.Run("ContainerHeap", func(b *testing.B) {
+		h := &intHeap{}
+		for i := 0; i < b.N; i++ {
+			heap.Push(h, i*7919%1000)
+			if h.Len() > 1000 {
+				heap.Pop(h)
+			}
+		}
+	})
+}
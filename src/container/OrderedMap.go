@@ -0,0 +1,167 @@
+package container
+
+import (
+	"math/rand"
+	"time"
+)
+
+const skipListMaxLevel = 32
+
+type skipNode struct {
+	key   interface{}
+	value interface{}
+	next  []*skipNode
+}
+
+// OrderedMap is a skip list keeping keys sorted by compare,
+// which returns a negative number, zero or a positive number like strings.Compare
+type OrderedMap struct {
+	head    *skipNode
+	level   int
+	length  int
+	compare func(a, b interface{}) int
+	random  *rand.Rand
+}
+
+func NewOrderedMap(compare func(a, b interface{}) int) *OrderedMap {
+	return &OrderedMap{
+		head:    &skipNode{next: make([]*skipNode, skipListMaxLevel)},
+		level:   1,
+		compare: compare,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (m *OrderedMap) Len() int {
+	return m.length
+}
+
+func (m *OrderedMap) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && m.random.Intn(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// seek fills update with the last node before key on every level and returns the first node not less than key
+func (m *OrderedMap) seek(key interface{}, update []*skipNode) *skipNode {
+	node := m.head
+	for i := m.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && m.compare(node.next[i].key, key) < 0 {
+			node = node.next[i]
+		}
+		if update != nil {
+			update[i] = node
+		}
+	}
+	return node.next[0]
+}
+
+func (m *OrderedMap) Put(key, value interface{}) {
+	update := make([]*skipNode, skipListMaxLevel)
+	node := m.seek(key, update)
+	if node != nil && m.compare(node.key, key) == 0 {
+		node.value = value
+		return
+	}
+	level := m.randomLevel()
+	for i := m.level; i < level; i++ {
+		update[i] = m.head
+	}
+	if level > m.level {
+		m.level = level
+	}
+	node = &skipNode{key, value, make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	m.length++
+}
+
+func (m *OrderedMap) Get(key interface{}) (interface{}, bool) {
+	node := m.seek(key, nil)
+	if node != nil && m.compare(node.key, key) == 0 {
+		return node.value, true
+	}
+	return nil, false
+}
+
+func (m *OrderedMap) Delete(key interface{}) bool {
+	update := make([]*skipNode, skipListMaxLevel)
+	node := m.seek(key, update)
+	if node == nil || m.compare(node.key, key) != 0 {
+		return false
+	}
+	for i := range node.next {
+		update[i].next[i] = node.next[i]
+	}
+	for m.level > 1 && m.head.next[m.level-1] == nil {
+		m.level--
+	}
+	m.length--
+	return true
+}
+
+// First returns the smallest key
+func (m *OrderedMap) First() (interface{}, interface{}, bool) {
+	node := m.head.next[0]
+	if node == nil {
+		return nil, nil, false
+	}
+	return node.key, node.value, true
+}
+
+// Last returns the greatest key
+func (m *OrderedMap) Last() (interface{}, interface{}, bool) {
+	node := m.head
+	for i := m.level - 1; i >= 0; i-- {
+		for node.next[i] != nil {
+			node = node.next[i]
+		}
+	}
+	if node == m.head {
+		return nil, nil, false
+	}
+	return node.key, node.value, true
+}
+
+// Ceiling returns the smallest key not less than key
+func (m *OrderedMap) Ceiling(key interface{}) (interface{}, interface{}, bool) {
+	node := m.seek(key, nil)
+	if node == nil {
+		return nil, nil, false
+	}
+	return node.key, node.value, true
+}
+
+// Floor returns the greatest key not greater than key
+func (m *OrderedMap) Floor(key interface{}) (interface{}, interface{}, bool) {
+	update := make([]*skipNode, skipListMaxLevel)
+	node := m.seek(key, update)
+	if node != nil && m.compare(node.key, key) == 0 {
+		return node.key, node.value, true
+	}
+	if update[0] == m.head {
+		return nil, nil, false
+	}
+	return update[0].key, update[0].value, true
+}
+
+// Range visits keys in [from, to) in ascending order until visitor returns false,
+// a nil bound leaves that side open
+func (m *OrderedMap) Range(from, to interface{}, visitor func(key, value interface{}) bool) {
+	node := m.head.next[0]
+	if from != nil {
+		node = m.seek(from, nil)
+	}
+	for ; node != nil; node = node.next[0] {
+		if to != nil && m.compare(node.key, to) >= 0 {
+			return
+		}
+		if !visitor(node.key, node.value) {
+			return
+		}
+	}
+}
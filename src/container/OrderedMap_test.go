@@ -0,0 +1,111 @@
+package container
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func compareStrings(a, b interface{}) int {
+	return strings.Compare(a.(string), b.(string))
+}
+
+func compareInts(a, b interface{}) int {
+	return a.(int) - b.(int)
+}
+
+func TestOrderedMapMatchesMap(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	m := NewOrderedMap(compareStrings)
+	expected := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := string(rune('a'+random.Intn(26))) + string(rune('a'+random.Intn(26)))
+		if random.Intn(3) == 0 {
+			_, exist := expected[key]
+			if m.Delete(key) != exist {
+				t.Fatalf("Delete(%q) disagrees with the map", key)
+			}
+			delete(expected, key)
+		} else {
+			m.Put(key, i)
+			expected[key] = i
+		}
+	}
+
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	visited := make([]string, 0, len(keys))
+	m.Range(nil, nil, func(key, value interface{}) bool {
+		if value.(int) != expected[key.(string)] {
+			t.Fatalf("%q holds %v, want %d", key, value, expected[key.(string)])
+		}
+		visited = append(visited, key.(string))
+		return true
+	})
+	if strings.Join(visited, ",") != strings.Join(keys, ",") || m.Len() != len(keys) {
+		t.Fatalf("Range visited %v, want %v", visited, keys)
+	}
+	for _, key := range keys {
+		if value, ok := m.Get(key); !ok || value.(int) != expected[key] {
+			t.Fatalf("Get(%q) = %v, %v", key, value, ok)
+		}
+	}
+	if first, _, _ := m.First(); first.(string) != keys[0] {
+		t.Fatalf("First is %v, want %s", first, keys[0])
+	}
+	if last, _, _ := m.Last(); last.(string) != keys[len(keys)-1] {
+		t.Fatalf("Last is %v, want %s", last, keys[len(keys)-1])
+	}
+}
+
+func TestOrderedMapNeighbours(t *testing.T) {
+	m := NewOrderedMap(compareInts)
+	for _, key := range []int{10, 30, 20, 40} {
+		m.Put(key, key*10)
+	}
+	if key, _, ok := m.Floor(25); !ok || key.(int) != 20 {
+		t.Fatalf("Floor(25) = %v, %v", key, ok)
+	}
+	if key, _, ok := m.Floor(30); !ok || key.(int) != 30 {
+		t.Fatalf("Floor(30) = %v, %v", key, ok)
+	}
+	if _, _, ok := m.Floor(5); ok {
+		t.Fatal("Floor(5) found a key")
+	}
+	if key, _, ok := m.Ceiling(25); !ok || key.(int) != 30 {
+		t.Fatalf("Ceiling(25) = %v, %v", key, ok)
+	}
+	if _, _, ok := m.Ceiling(45); ok {
+		t.Fatal("Ceiling(45) found a key")
+	}
+	visited := make([]int, 0)
+	m.Range(20, 40, func(key, value interface{}) bool {
+		visited = append(visited, key.(int))
+		return true
+	})
+	if len(visited) != 2 || visited[0] != 20 || visited[1] != 30 {
+		t.Fatalf("Range(20, 40) visited %v", visited)
+	}
+}
+
+func BenchmarkOrderedMapPut(b *testing.B) {
+	m := NewOrderedMap(compareInts)
+	for i := 0; i < b.N; i++ {
+		m.Put(i*7919%100000, i)
+	}
+}
+
+func BenchmarkOrderedMapGet(b *testing.B) {
+	m := NewOrderedMap(compareInts)
+	for i := 0; i < 100000; i++ {
+		m.Put(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Get(i * 7919 % 100000)
+	}
+}
@@ -0,0 +1,70 @@
+package container
+
+// RingBuffer keeps the latest Cap() items, pushing into a full buffer overwrites the oldest one
+type RingBuffer struct {
+	head   int
+	length int
+	items  []interface{}
+}
+
+func NewRingBuffer(capacity int) *RingBuffer {
+	if capacity < 1 {
+		panic("container: ring buffer capacity must be positive")
+	}
+	return &RingBuffer{0, 0, make([]interface{}, capacity)}
+}
+
+func (buffer *RingBuffer) Len() int {
+	return buffer.length
+}
+
+func (buffer *RingBuffer) Cap() int {
+	return len(buffer.items)
+}
+
+func (buffer *RingBuffer) IsFull() bool {
+	return buffer.length == len(buffer.items)
+}
+
+// Push appends item and returns the overwritten one when the buffer was full
+func (buffer *RingBuffer) Push(item interface{}) (interface{}, bool) {
+	tail := (buffer.head + buffer.length) % len(buffer.items)
+	if buffer.IsFull() {
+		evicted := buffer.items[tail]
+		buffer.items[tail] = item
+		buffer.head = (buffer.head + 1) % len(buffer.items)
+		return evicted, true
+	}
+	buffer.items[tail] = item
+	buffer.length++
+	return nil, false
+}
+
+// Pop removes and returns the oldest item
+func (buffer *RingBuffer) Pop() (interface{}, bool) {
+	if buffer.length == 0 {
+		return nil, false
+	}
+	item := buffer.items[buffer.head]
+	buffer.items[buffer.head] = nil
+	buffer.head = (buffer.head + 1) % len(buffer.items)
+	buffer.length--
+	return item, true
+}
+
+// Peek returns the oldest item without removing it
+func (buffer *RingBuffer) Peek() (interface{}, bool) {
+	if buffer.length == 0 {
+		return nil, false
+	}
+	return buffer.items[buffer.head], true
+}
+
+// Items copies the content from the oldest to the newest item
+func (buffer *RingBuffer) Items() []interface{} {
+	result := make([]interface{}, buffer.length)
+	for i := range result {
+		result[i] = buffer.items[(buffer.head+i)%len(buffer.items)]
+	}
+	return result
+}
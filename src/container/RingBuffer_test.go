@@ -0,0 +1,45 @@
+package container
+
+import "testing"
+
+func TestRingBufferOverwritesOldest(t *testing.T) {
+	buffer := NewRingBuffer(3)
+	for i := 0; i < 3; i++ {
+		if _, overwritten := buffer.Push(i); overwritten {
+			t.Fatalf("Push(%d) overwrote an item of a buffer with room", i)
+		}
+	}
+	if !buffer.IsFull() || buffer.Cap() != 3 {
+		t.Fatalf("buffer of %d/%d items is not full", buffer.Len(), buffer.Cap())
+	}
+	for i := 3; i < 5; i++ {
+		if old, overwritten := buffer.Push(i); !overwritten || old.(int) != i-3 {
+			t.Fatalf("Push(%d) overwrote %v, %v", i, old, overwritten)
+		}
+	}
+	items := buffer.Items()
+	if len(items) != 3 || items[0].(int) != 2 || items[2].(int) != 4 {
+		t.Fatalf("Items returned %v", items)
+	}
+	if oldest, _ := buffer.Peek(); oldest.(int) != 2 {
+		t.Fatalf("Peek returned %v", oldest)
+	}
+	for i := 2; i < 5; i++ {
+		if item, ok := buffer.Pop(); !ok || item.(int) != i {
+			t.Fatalf("Pop returned %v, %v, want %d", item, ok, i)
+		}
+	}
+	if _, ok := buffer.Pop(); ok || buffer.Len() != 0 {
+		t.Fatal("buffer not empty")
+	}
+}
+
+func BenchmarkRingBuffer(b *testing.B) {
+	buffer := NewRingBuffer(1024)
+	for i := 0; i < b.N; i++ {
+		buffer.Push(i)
+		if i%3 == 0 {
+			buffer.Pop()
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package hls
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrNotPlaylist = errors.New("hls: missing #EXTM3U header")
+
+// ParseError tells which line of a playlist could not be understood
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("hls: line %d: %s", e.Line, e.Message)
+}
+
+// parseAttributes splits an attribute list like METHOD=AES-128,URI="key.bin" into its values,
+// quoted values lose their quotes and may contain commas
+func parseAttributes(list string) map[string]string {
+	result := make(map[string]string)
+	for len(list) > 0 {
+		equal := strings.IndexByte(list, '=')
+		if equal < 0 {
+			break
+		}
+		name := strings.TrimSpace(list[:equal])
+		list = list[equal+1:]
+		var value string
+		if strings.HasPrefix(list, `"`) {
+			end := strings.IndexByte(list[1:], '"')
+			if end < 0 {
+				value, list = list[1:], ""
+			} else {
+				value, list = list[1:end+1], list[end+2:]
+			}
+			if comma := strings.IndexByte(list, ','); comma >= 0 {
+				list = list[comma+1:]
+			} else {
+				list = ""
+			}
+		} else if comma := strings.IndexByte(list, ','); comma >= 0 {
+			value, list = list[:comma], list[comma+1:]
+		} else {
+			value, list = list, ""
+		}
+		result[name] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// parseByteRange reads "length[@offset]", a missing offset is -1
+func parseByteRange(s string) (*ByteRange, error) {
+	result := &ByteRange{Offset: -1}
+	if at := strings.IndexByte(s, '@'); at >= 0 {
+		offset, e := strconv.ParseInt(s[at+1:], 10, 64)
+		if e != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid byte range %q", s)
+		}
+		result.Offset = offset
+		s = s[:at]
+	}
+	length, e := strconv.ParseInt(s, 10, 64)
+	if e != nil || length < 0 {
+		return nil, fmt.Errorf("invalid byte range %q", s)
+	}
+	result.Length = length
+	return result, nil
+}
+
+// isIdentityFormat reports whether a KEYFORMAT means the key file holds the key itself
+func isIdentityFormat(format string) bool {
+	return format == "" || format == "identity"
+}
+
+func parseIV(s string) ([]byte, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(digits) == 0 || len(digits) > 32 {
+		return nil, fmt.Errorf("invalid IV %q", s)
+	}
+	iv, e := hex.DecodeString(strings.Repeat("0", 32-len(digits)) + digits)
+	if e != nil {
+		return nil, fmt.Errorf("invalid IV %q", s)
+	}
+	return iv, nil
+}
+
+func parseVariant(attributes map[string]string) (*Variant, error) {
+	variant := &Variant{
+		Codecs:         attributes["CODECS"],
+		Audio:          attributes["AUDIO"],
+		Video:          attributes["VIDEO"],
+		Subtitles:      attributes["SUBTITLES"],
+		ClosedCaptions: attributes["CLOSED-CAPTIONS"],
+	}
+	var e error
+	if variant.Bandwidth, e = strconv.ParseInt(attributes["BANDWIDTH"], 10, 64); e != nil {
+		return nil, fmt.Errorf("invalid BANDWIDTH %q", attributes["BANDWIDTH"])
+	}
+	if s, exist := attributes["AVERAGE-BANDWIDTH"]; exist {
+		if variant.AverageBandwidth, e = strconv.ParseInt(s, 10, 64); e != nil {
+			return nil, fmt.Errorf("invalid AVERAGE-BANDWIDTH %q", s)
+		}
+	}
+	if s, exist := attributes["RESOLUTION"]; exist {
+		x := strings.IndexAny(s, "xX")
+		if x < 0 {
+			return nil, fmt.Errorf("invalid RESOLUTION %q", s)
+		}
+		width, e1 := strconv.Atoi(s[:x])
+		height, e2 := strconv.Atoi(s[x+1:])
+		if e1 != nil || e2 != nil {
+			return nil, fmt.Errorf("invalid RESOLUTION %q", s)
+		}
+		variant.Resolution = Resolution{width, height}
+	}
+	if s, exist := attributes["FRAME-RATE"]; exist {
+		if variant.FrameRate, e = strconv.ParseFloat(s, 64); e != nil {
+			return nil, fmt.Errorf("invalid FRAME-RATE %q", s)
+		}
+	}
+	return variant, nil
+}
+
+func parseRendition(attributes map[string]string) (*Rendition, error) {
+	rendition := &Rendition{
+		Type:            attributes["TYPE"],
+		GroupID:         attributes["GROUP-ID"],
+		Name:            attributes["NAME"],
+		Language:        attributes["LANGUAGE"],
+		URI:             attributes["URI"],
+		Default:         attributes["DEFAULT"] == "YES",
+		AutoSelect:      attributes["AUTOSELECT"] == "YES",
+		Forced:          attributes["FORCED"] == "YES",
+		InstreamID:      attributes["INSTREAM-ID"],
+		Characteristics: attributes["CHARACTERISTICS"],
+		Channels:        attributes["CHANNELS"],
+	}
+	if rendition.Type == "" || rendition.GroupID == "" || rendition.Name == "" {
+		return nil, errors.New("EXT-X-MEDIA needs TYPE, GROUP-ID and NAME")
+	}
+	return rendition, nil
+}
+
+// parser keeps the state carried from tag to tag
+type parser struct {
+	master      *MasterPlaylist
+	media       *MediaPlaylist
+	isMaster    bool
+	isMedia     bool
+	variant     *Variant
+	segment     *Segment
+	key         *Key
+	initSection *Map
+	// End of the last byte range of every resource, for ranges without an offset
+	rangeEnds map[string]int64
+	// keyRead is set by an EXT-X-KEY and cleared by the next segment
+	keyRead bool
+}
+
+func (p *parser) pendingSegment() *Segment {
+	if p.segment == nil {
+		p.segment = new(Segment)
+	}
+	return p.segment
+}
+
+func (p *parser) tag(name, value string) error {
+	switch name {
+	case "#EXT-X-VERSION":
+		version, e := strconv.Atoi(value)
+		if e != nil {
+			return fmt.Errorf("invalid version %q", value)
+		}
+		p.master.Version, p.media.Version = version, version
+	case "#EXT-X-INDEPENDENT-SEGMENTS":
+		p.master.IndependentSegments, p.media.IndependentSegments = true, true
+	case "#EXT-X-STREAM-INF":
+		variant, e := parseVariant(parseAttributes(value))
+		if e != nil {
+			return e
+		}
+		p.isMaster = true
+		p.variant = variant
+	case "#EXT-X-MEDIA":
+		rendition, e := parseRendition(parseAttributes(value))
+		if e != nil {
+			return e
+		}
+		p.isMaster = true
+		p.master.Renditions = append(p.master.Renditions, rendition)
+	case "#EXT-X-TARGETDURATION":
+		duration, e := strconv.Atoi(value)
+		if e != nil {
+			return fmt.Errorf("invalid target duration %q", value)
+		}
+		p.isMedia = true
+		p.media.TargetDuration = duration
+	case "#EXT-X-MEDIA-SEQUENCE":
+		sequence, e := strconv.ParseInt(value, 10, 64)
+		if e != nil {
+			return fmt.Errorf("invalid media sequence %q", value)
+		}
+		p.isMedia = true
+		p.media.MediaSequence = sequence
+	case "#EXT-X-DISCONTINUITY-SEQUENCE":
+		sequence, e := strconv.ParseInt(value, 10, 64)
+		if e != nil {
+			return fmt.Errorf("invalid discontinuity sequence %q", value)
+		}
+		p.isMedia = true
+		p.media.DiscontinuitySequence = sequence
+	case "#EXT-X-PLAYLIST-TYPE":
+		p.isMedia = true
+		p.media.PlaylistType = value
+	case "#EXT-X-ENDLIST":
+		p.isMedia = true
+		p.media.EndList = true
+	case "#EXTINF":
+		title := ""
+		if comma := strings.IndexByte(value, ','); comma >= 0 {
+			value, title = value[:comma], value[comma+1:]
+		}
+		duration, e := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if e != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		p.isMedia = true
+		segment := p.pendingSegment()
+		segment.Duration, segment.Title = duration, title
+	case "#EXT-X-BYTERANGE":
+		byteRange, e := parseByteRange(value)
+		if e != nil {
+			return e
+		}
+		p.isMedia = true
+		p.pendingSegment().ByteRange = byteRange
+	case "#EXT-X-DISCONTINUITY":
+		p.isMedia = true
+		p.pendingSegment().Discontinuity = true
+	case "#EXT-X-KEY":
+		attributes := parseAttributes(value)
+		p.isMedia = true
+		// Keys in a row are alternatives for the same segments, the identity one is the only one usable
+		// without a DRM system. Another format is still kept when alone, so that encrypted segments never
+		// pass for clear ones.
+		alternative := p.keyRead
+		p.keyRead = true
+		if !isIdentityFormat(attributes["KEYFORMAT"]) && alternative && p.key != nil && isIdentityFormat(p.key.KeyFormat) {
+			return nil
+		}
+		if attributes["METHOD"] == "NONE" {
+			p.key = nil
+			return nil
+		}
+		key := &Key{
+			Method:            attributes["METHOD"],
+			URI:               attributes["URI"],
+			KeyFormat:         attributes["KEYFORMAT"],
+			KeyFormatVersions: attributes["KEYFORMATVERSIONS"],
+		}
+		if key.Method == "" || key.URI == "" {
+			return errors.New("EXT-X-KEY needs METHOD and URI")
+		}
+		if iv, exist := attributes["IV"]; exist {
+			var e error
+			if key.IV, e = parseIV(iv); e != nil {
+				return e
+			}
+		}
+		p.key = key
+	case "#EXT-X-MAP":
+		attributes := parseAttributes(value)
+		initSection := &Map{URI: attributes["URI"]}
+		if initSection.URI == "" {
+			return errors.New("EXT-X-MAP needs URI")
+		}
+		if s, exist := attributes["BYTERANGE"]; exist {
+			byteRange, e := parseByteRange(s)
+			if e != nil {
+				return e
+			}
+			if byteRange.Offset < 0 {
+				byteRange.Offset = 0
+			}
+			initSection.ByteRange = byteRange
+		}
+		p.isMedia = true
+		p.initSection = initSection
+	}
+	// Other tags are valid but not kept
+	return nil
+}
+
+func (p *parser) uri(uri string) {
+	if p.variant != nil {
+		p.variant.URI = uri
+		p.master.Variants = append(p.master.Variants, p.variant)
+		p.variant = nil
+		return
+	}
+	segment := p.pendingSegment()
+	p.segment = nil
+	segment.URI = uri
+	segment.Sequence = p.media.MediaSequence + int64(len(p.media.Segments))
+	segment.Key = p.key
+	p.keyRead = false
+	segment.Map = p.initSection
+	if segment.ByteRange != nil {
+		if segment.ByteRange.Offset < 0 {
+			segment.ByteRange.Offset = p.rangeEnds[uri]
+		}
+		p.rangeEnds[uri] = segment.ByteRange.Offset + segment.ByteRange.Length
+	}
+	p.isMedia = true
+	p.media.Segments = append(p.media.Segments, segment)
+}
+
+// Parse reads a master or a media playlist, URIs are left as written
+func Parse(r io.Reader) (Playlist, error) {
+	p := &parser{
+		master:    new(MasterPlaylist),
+		media:     new(MediaPlaylist),
+		rangeEnds: make(map[string]int64),
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	number := 0
+	header := false
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if !header {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if line == "" {
+				continue
+			}
+			if line != "#EXTM3U" {
+				return nil, ErrNotPlaylist
+			}
+			header = true
+			continue
+		}
+		if line == "" || (strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "#EXT")) {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			p.uri(line)
+			continue
+		}
+		name, value := line, ""
+		if colon := strings.IndexByte(line, ':'); colon >= 0 {
+			name, value = line[:colon], line[colon+1:]
+		}
+		if e := p.tag(name, value); e != nil {
+			return nil, &ParseError{number, e.Error()}
+		}
+		if p.isMaster && p.isMedia {
+			return nil, &ParseError{number, "master and media playlist tags mixed"}
+		}
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	if !header {
+		return nil, ErrNotPlaylist
+	}
+	if p.isMaster {
+		return p.master, nil
+	}
+	return p.media, nil
+}
+
+// ParseString is Parse over the content of a playlist
+func ParseString(content string) (Playlist, error) {
+	return Parse(strings.NewReader(content))
+}
@@ -0,0 +1,224 @@
+package hls
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const testMediaPlaylist = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="700@0"
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example/key?id=1",IV=0x1
+#EXTINF:5.5,first
+#EXT-X-BYTERANGE:1000@700
+main.mp4
+#EXTINF:5.5,
+#EXT-X-BYTERANGE:1000
+main.mp4
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4,
+../other/seg.m4s?n=1
+#EXT-X-ENDLIST
+`
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English, main",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,AVERAGE-BANDWIDTH=1000000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.970,AUDIO="audio",CLOSED-CAPTIONS=NONE
+720p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=640000
+http://cdn.example/360p.m3u8
+`
+
+func TestParseDetectsPlaylistType(t *testing.T) {
+	for _, test := range []struct {
+		name, content string
+		master        bool
+		e             error
+	}{
+		{"master", testMasterPlaylist, true, nil},
+		{"media", testMediaPlaylist, false, nil},
+		{"media without segments", "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-ENDLIST\n", false, nil},
+		{"header only", "#EXTM3U\n", false, nil},
+		{"renditions only", "#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"a\",NAME=\"a\"\n", true, nil},
+		{"byte order mark", "\ufeff#EXTM3U\n#EXTINF:4,\na.ts\n", false, nil},
+		{"missing header", "#EXTINF:4,\na.ts\n", false, ErrNotPlaylist},
+		{"empty", "", false, ErrNotPlaylist},
+	} {
+		playlist, e := ParseString(test.content)
+		if e != test.e {
+			t.Errorf("%s: got error %v, want %v", test.name, e, test.e)
+			continue
+		}
+		if e != nil {
+			continue
+		}
+		if _, master := playlist.(*MasterPlaylist); master != test.master {
+			t.Errorf("%s: got %T", test.name, playlist)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, content := range []string{
+		"#EXTM3U\n#EXTINF:x,\na.ts\n",
+		"#EXTM3U\n#EXT-X-BYTERANGE:10@x\na.ts\n",
+		"#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\",IV=0xZZ\na.ts\n",
+		"#EXTM3U\n#EXT-X-KEY:METHOD=AES-128\na.ts\n",
+		"#EXTM3U\n#EXT-X-STREAM-INF:RESOLUTION=1x1\nv.m3u8\n",
+		"#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nv.m3u8\n#EXTINF:4,\na.ts\n",
+	} {
+		if _, e := ParseString(content); e == nil {
+			t.Errorf("no error parsing %q", content)
+		} else if _, ok := e.(*ParseError); !ok {
+			t.Errorf("parsing %q: got %T, want a *ParseError", content, e)
+		}
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	for _, test := range []struct {
+		name, tags string
+		expected   []ByteRange
+	}{
+		{"with offsets", "#EXT-X-BYTERANGE:100@0\na.ts\n#EXT-X-BYTERANGE:50@400\na.ts\n", []ByteRange{{100, 0}, {50, 400}}},
+		{"without offsets", "#EXT-X-BYTERANGE:100\na.ts\n#EXT-X-BYTERANGE:50\na.ts\n", []ByteRange{{100, 0}, {50, 100}}},
+		{"continuing an offset", "#EXT-X-BYTERANGE:100@20\na.ts\n#EXT-X-BYTERANGE:50\na.ts\n", []ByteRange{{100, 20}, {50, 120}}},
+		{"per resource", "#EXT-X-BYTERANGE:100\na.ts\n#EXT-X-BYTERANGE:30\nb.ts\n#EXT-X-BYTERANGE:50\na.ts\n",
+			[]ByteRange{{100, 0}, {30, 0}, {50, 100}}},
+	} {
+		playlist, e := ParseString("#EXTM3U\n" + test.tags)
+		if e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		segments := playlist.(*MediaPlaylist).Segments
+		if len(segments) != len(test.expected) {
+			t.Errorf("%s: got %d segments, want %d", test.name, len(segments), len(test.expected))
+			continue
+		}
+		for i := range segments {
+			if segments[i].ByteRange == nil || *segments[i].ByteRange != test.expected[i] {
+				t.Errorf("%s: segment %d has range %+v, want %+v", test.name, i, segments[i].ByteRange, test.expected[i])
+			}
+		}
+	}
+}
+
+func TestParseMap(t *testing.T) {
+	playlist, e := ParseString(`#EXTM3U
+#EXTINF:4,
+a.ts
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="700@100"
+#EXTINF:4,
+b.m4s
+#EXTINF:4,
+c.m4s
+#EXT-X-MAP:URI="init2.mp4"
+#EXTINF:4,
+d.m4s
+`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	segments := playlist.(*MediaPlaylist).Segments
+	if segments[0].Map != nil {
+		t.Errorf("a segment before any map has %+v", segments[0].Map)
+	}
+	if segments[1].Map == nil || segments[1].Map.URI != "init.mp4" || *segments[1].Map.ByteRange != (ByteRange{700, 100}) {
+		t.Errorf("got map %+v", segments[1].Map)
+	}
+	if segments[2].Map != segments[1].Map {
+		t.Error("segments under the same map do not share it")
+	}
+	if segments[3].Map == nil || segments[3].Map.URI != "init2.mp4" || segments[3].Map.ByteRange != nil {
+		t.Errorf("got map %+v", segments[3].Map)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, test := range []struct {
+		name, tag string
+		expected  *Key
+	}{
+		{"without IV", `#EXT-X-KEY:METHOD=AES-128,URI="k.bin"`, &Key{Method: "AES-128", URI: "k.bin"}},
+		{"with IV", `#EXT-X-KEY:METHOD=AES-128,URI="k.bin",IV=0x000102030405060708090a0b0c0d0e0f`,
+			&Key{Method: "AES-128", URI: "k.bin", IV: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}}},
+		{"short IV padded", `#EXT-X-KEY:METHOD=AES-128,URI="k.bin",IV=0X1a2`,
+			&Key{Method: "AES-128", URI: "k.bin", IV: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0xa2}}},
+		{"identity format", `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="k.bin",KEYFORMAT="identity",KEYFORMATVERSIONS="1"`,
+			&Key{Method: "SAMPLE-AES", URI: "k.bin", KeyFormat: "identity", KeyFormatVersions: "1"}},
+		{"other format", `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://k",KEYFORMAT="com.apple.streamingkeydelivery"`,
+			&Key{Method: "SAMPLE-AES", URI: "skd://k", KeyFormat: "com.apple.streamingkeydelivery"}},
+		{"identity before another format", "#EXT-X-KEY:METHOD=AES-128,URI=\"k.bin\"\n" +
+			`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://k",KEYFORMAT="com.apple.streamingkeydelivery"`, &Key{Method: "AES-128", URI: "k.bin"}},
+		{"identity after another format", `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://k",KEYFORMAT="com.apple.streamingkeydelivery"` +
+			"\n#EXT-X-KEY:METHOD=AES-128,URI=\"k.bin\"", &Key{Method: "AES-128", URI: "k.bin"}},
+		{"none", "#EXT-X-KEY:METHOD=AES-128,URI=\"k.bin\"\n#EXTINF:4,\na.ts\n#EXT-X-KEY:METHOD=NONE", nil},
+	} {
+		playlist, e := ParseString("#EXTM3U\n" + test.tag + "\n#EXTINF:4,\nb.ts\n")
+		if e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		segments := playlist.(*MediaPlaylist).Segments
+		if key := segments[len(segments)-1].Key; !reflect.DeepEqual(key, test.expected) {
+			t.Errorf("%s: got %+v, want %+v", test.name, key, test.expected)
+		}
+	}
+}
+
+func TestParseMediaPlaylist(t *testing.T) {
+	playlist, e := ParseString(testMediaPlaylist)
+	if e != nil {
+		t.Fatal(e)
+	}
+	media := playlist.(*MediaPlaylist)
+	if media.Version != 7 || media.TargetDuration != 6 || media.MediaSequence != 100 || media.PlaylistType != "VOD" || !media.EndList {
+		t.Errorf("got %+v", media)
+	}
+	for i, expected := range []Segment{
+		{URI: "main.mp4", Duration: 5.5, Title: "first", Sequence: 100},
+		{URI: "main.mp4", Duration: 5.5, Sequence: 101},
+		{URI: "../other/seg.m4s?n=1", Duration: 4, Sequence: 102, Discontinuity: true},
+	} {
+		segment := *media.Segments[i]
+		segment.ByteRange, segment.Key, segment.Map = nil, nil, nil
+		if segment != expected {
+			t.Errorf("segment %d: got %+v, want %+v", i, segment, expected)
+		}
+	}
+	if media.Segments[2].Key != nil || media.Segments[0].Key != media.Segments[1].Key {
+		t.Error("keys are not carried until METHOD=NONE")
+	}
+}
+
+func TestParseMasterPlaylist(t *testing.T) {
+	playlist, e := ParseString(testMasterPlaylist)
+	if e != nil {
+		t.Fatal(e)
+	}
+	master := playlist.(*MasterPlaylist)
+	expected := &MasterPlaylist{
+		IndependentSegments: true,
+		Variants: []*Variant{
+			{URI: "720p/index.m3u8", Bandwidth: 1280000, AverageBandwidth: 1000000, Codecs: "avc1.4d401f,mp4a.40.2",
+				Resolution: Resolution{1280, 720}, FrameRate: 29.97, Audio: "audio", ClosedCaptions: "NONE"},
+			{URI: "http://cdn.example/360p.m3u8", Bandwidth: 640000},
+		},
+		Renditions: []*Rendition{
+			{Type: "AUDIO", GroupID: "audio", Name: "English, main", Language: "en", URI: "audio/en.m3u8", Default: true, AutoSelect: true},
+		},
+	}
+	if !reflect.DeepEqual(master, expected) {
+		var got, want bytes.Buffer
+		master.Encode(&got)
+		expected.Encode(&want)
+		t.Errorf("got\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
@@ -0,0 +1,99 @@
+package hls
+
+import "io"
+
+// Playlist is either a *MasterPlaylist or a *MediaPlaylist
+type Playlist interface {
+	Encode(w io.Writer) error
+	// Resolve makes every URI of the playlist absolute, relative ones are taken relative to base
+	Resolve(base string) error
+}
+
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// Variant is an #EXT-X-STREAM-INF entry of a master playlist
+type Variant struct {
+	URI              string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Codecs           string
+	Resolution       Resolution
+	FrameRate        float64
+	// Group ids of the renditions to play with this variant
+	Audio          string
+	Video          string
+	Subtitles      string
+	ClosedCaptions string
+}
+
+// Rendition is an #EXT-X-MEDIA entry of a master playlist, like an alternate audio track.
+// URI is empty when the rendition is muxed into the variants.
+type Rendition struct {
+	Type            string
+	GroupID         string
+	Name            string
+	Language        string
+	URI             string
+	Default         bool
+	AutoSelect      bool
+	Forced          bool
+	InstreamID      string
+	Characteristics string
+	Channels        string
+}
+
+type MasterPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	Variants            []*Variant
+	Renditions          []*Rendition
+}
+
+// ByteRange selects Length bytes from Offset, when a tag leaves the offset out
+// the parser fills in the end of the previous range of the same resource
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// Key is an #EXT-X-KEY, IV is nil when the tag has none and the media sequence number is to be used
+type Key struct {
+	Method            string
+	URI               string
+	IV                []byte
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+// Map is an #EXT-X-MAP, the initialization section of the segments following it
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+}
+
+// Segment is a media segment. Key and Map are the tags in effect for it, segments share them by pointer.
+type Segment struct {
+	URI           string
+	Duration      float64
+	Title         string
+	Sequence      int64
+	ByteRange     *ByteRange
+	Key           *Key
+	Map           *Map
+	Discontinuity bool
+}
+
+type MediaPlaylist struct {
+	Version               int
+	TargetDuration        int
+	MediaSequence         int64
+	DiscontinuitySequence int64
+	// PlaylistType is VOD, EVENT or empty
+	PlaylistType        string
+	IndependentSegments bool
+	EndList             bool
+	Segments            []*Segment
+}
@@ -0,0 +1,129 @@
+package hls
+
+import "strings"
+
+// VariantSelector picks one of the variants of a master playlist, nil when none fits
+type VariantSelector func(variants []*Variant) *Variant
+
+// HighestBandwidth picks the variant with the most bits per second
+func HighestBandwidth() VariantSelector {
+	return func(variants []*Variant) *Variant {
+		var result *Variant
+		for _, variant := range variants {
+			if result == nil || variant.Bandwidth > result.Bandwidth {
+				result = variant
+			}
+		}
+		return result
+	}
+}
+
+// LowestBandwidth picks the variant with the fewest bits per second
+func LowestBandwidth() VariantSelector {
+	return func(variants []*Variant) *Variant {
+		var result *Variant
+		for _, variant := range variants {
+			if result == nil || variant.Bandwidth < result.Bandwidth {
+				result = variant
+			}
+		}
+		return result
+	}
+}
+
+// ClosestResolution picks the variant whose pixel count is the nearest to width x height,
+// the higher bandwidth wins a tie. Variants without RESOLUTION are only picked when no other is left.
+func ClosestResolution(width, height int) VariantSelector {
+	target := int64(width) * int64(height)
+	distance := func(variant *Variant) int64 {
+		if variant.Resolution.Width == 0 || variant.Resolution.Height == 0 {
+			return 1<<63 - 1
+		}
+		d := int64(variant.Resolution.Width)*int64(variant.Resolution.Height) - target
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	return func(variants []*Variant) *Variant {
+		var result *Variant
+		for _, variant := range variants {
+			if result == nil {
+				result = variant
+				continue
+			}
+			if d, best := distance(variant), distance(result); d < best || (d == best && variant.Bandwidth > result.Bandwidth) {
+				result = variant
+			}
+		}
+		return result
+	}
+}
+
+// WithCodec keeps the variants listing a codec starting with codec, like "avc1" or "hvc1",
+// and lets then pick among them
+func WithCodec(codec string, then VariantSelector) VariantSelector {
+	return func(variants []*Variant) *Variant {
+		matching := make([]*Variant, 0, len(variants))
+		for _, variant := range variants {
+			for _, c := range strings.Split(variant.Codecs, ",") {
+				if strings.HasPrefix(strings.TrimSpace(c), codec) {
+					matching = append(matching, variant)
+					break
+				}
+			}
+		}
+		return then(matching)
+	}
+}
+
+// Select returns the variant picked by selector, nil when none fits
+func (playlist *MasterPlaylist) Select(selector VariantSelector) *Variant {
+	return selector(playlist.Variants)
+}
+
+// Group returns the id of the rendition group of type renditionType used by variant, empty when there is none
+func (variant *Variant) Group(renditionType string) string {
+	switch renditionType {
+	case "AUDIO":
+		return variant.Audio
+	case "VIDEO":
+		return variant.Video
+	case "SUBTITLES":
+		return variant.Subtitles
+	case "CLOSED-CAPTIONS":
+		if variant.ClosedCaptions != "NONE" {
+			return variant.ClosedCaptions
+		}
+	}
+	return ""
+}
+
+// SetGroup replaces the rendition group of type renditionType used by variant
+func (variant *Variant) SetGroup(renditionType, group string) {
+	switch renditionType {
+	case "AUDIO":
+		variant.Audio = group
+	case "VIDEO":
+		variant.Video = group
+	case "SUBTITLES":
+		variant.Subtitles = group
+	case "CLOSED-CAPTIONS":
+		variant.ClosedCaptions = group
+	}
+}
+
+// RenditionsOf returns the renditions of type renditionType which may be played along variant
+func (playlist *MasterPlaylist) RenditionsOf(variant *Variant, renditionType string) []*Rendition {
+	group := variant.Group(renditionType)
+	result := make([]*Rendition, 0)
+	if group == "" {
+		return result
+	}
+	for _, rendition := range playlist.Renditions {
+		if rendition.Type == renditionType && rendition.GroupID == group {
+			result = append(result, rendition)
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package hls
+
+import "testing"
+
+var testVariants = []*Variant{
+	{URI: "audio-only", Bandwidth: 64000, Codecs: "mp4a.40.2"},
+	{URI: "360p", Bandwidth: 800000, Codecs: "avc1.4d401e,mp4a.40.2", Resolution: Resolution{640, 360}},
+	{URI: "720p", Bandwidth: 2500000, Codecs: "avc1.4d401f,mp4a.40.2", Resolution: Resolution{1280, 720}},
+	{URI: "720p-hevc", Bandwidth: 1800000, Codecs: "hvc1.2.4.L93, mp4a.40.2", Resolution: Resolution{1280, 720}},
+	{URI: "1080p", Bandwidth: 5000000, Codecs: "avc1.640028,mp4a.40.2", Resolution: Resolution{1920, 1080}},
+}
+
+func TestSelect(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		selector VariantSelector
+		expected string
+	}{
+		{"highest bandwidth", HighestBandwidth(), "1080p"},
+		{"lowest bandwidth", LowestBandwidth(), "audio-only"},
+		{"exact resolution, higher bandwidth on a tie", ClosestResolution(1280, 720), "720p"},
+		{"nearest resolution", ClosestResolution(1200, 700), "720p"},
+		{"below every resolution", ClosestResolution(100, 100), "360p"},
+		{"above every resolution", ClosestResolution(3840, 2160), "1080p"},
+		{"codec", WithCodec("hvc1", HighestBandwidth()), "720p-hevc"},
+		{"codec after a space", WithCodec("mp4a", LowestBandwidth()), "audio-only"},
+		{"codec then resolution", WithCodec("avc1", ClosestResolution(1280, 720)), "720p"},
+		{"codec prefix", WithCodec("avc1.64", LowestBandwidth()), "1080p"},
+		{"missing codec", WithCodec("vp09", HighestBandwidth()), ""},
+	} {
+		variant := (&MasterPlaylist{Variants: testVariants}).Select(test.selector)
+		uri := ""
+		if variant != nil {
+			uri = variant.URI
+		}
+		if uri != test.expected {
+			t.Errorf("%s: got %q, want %q", test.name, uri, test.expected)
+		}
+	}
+}
+
+func TestSelectWithoutVariants(t *testing.T) {
+	for name, selector := range map[string]VariantSelector{
+		"highest bandwidth":  HighestBandwidth(),
+		"lowest bandwidth":   LowestBandwidth(),
+		"closest resolution": ClosestResolution(1280, 720),
+		"codec":              WithCodec("avc1", HighestBandwidth()),
+	} {
+		if variant := new(MasterPlaylist).Select(selector); variant != nil {
+			t.Errorf("%s picked %+v out of nothing", name, variant)
+		}
+	}
+	// Without any RESOLUTION the closest one is still picked
+	if variant := ClosestResolution(1280, 720)(testVariants[:1]); variant == nil || variant.URI != "audio-only" {
+		t.Errorf("got %+v, want the variant without resolution", variant)
+	}
+}
+
+func TestRenditionsOf(t *testing.T) {
+	playlist, e := ParseString(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="a",NAME="en",LANGUAGE="en",URI="en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="a",NAME="fr",LANGUAGE="fr",URI="fr.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="b",NAME="de",LANGUAGE="de",URI="de.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="NONE",NAME="cc",INSTREAM-ID="CC1"
+#EXT-X-STREAM-INF:BANDWIDTH=1,AUDIO="a",CLOSED-CAPTIONS=NONE
+v.m3u8
+`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	master := playlist.(*MasterPlaylist)
+	variant := master.Variants[0]
+	if renditions := master.RenditionsOf(variant, "AUDIO"); len(renditions) != 2 || renditions[0].Name != "en" || renditions[1].Name != "fr" {
+		t.Errorf("got audio renditions %+v, want en and fr", renditions)
+	}
+	// NONE names no group, even one whose id happens to be NONE
+	if group := variant.Group("CLOSED-CAPTIONS"); group != "" {
+		t.Errorf("CLOSED-CAPTIONS=NONE is group %q", group)
+	}
+	if renditions := master.RenditionsOf(variant, "CLOSED-CAPTIONS"); len(renditions) != 0 {
+		t.Errorf("got closed captions %+v", renditions)
+	}
+	if renditions := master.RenditionsOf(variant, "SUBTITLES"); len(renditions) != 0 {
+		t.Errorf("got subtitles %+v", renditions)
+	}
+	variant.SetGroup("AUDIO", "b")
+	if renditions := master.RenditionsOf(variant, "AUDIO"); len(renditions) != 1 || renditions[0].Name != "de" {
+		t.Errorf("got audio renditions %+v after SetGroup, want de", renditions)
+	}
+}
@@ -0,0 +1,63 @@
+package hls
+
+import "net/url"
+
+// ResolveURI resolves reference against base the way a browser would, absolute references are kept as they are
+func ResolveURI(base, reference string) (string, error) {
+	baseURL, e := url.Parse(base)
+	if e != nil {
+		return "", e
+	}
+	referenceURL, e := url.Parse(reference)
+	if e != nil {
+		return "", e
+	}
+	return baseURL.ResolveReference(referenceURL).String(), nil
+}
+
+func (playlist *MasterPlaylist) Resolve(base string) error {
+	for _, variant := range playlist.Variants {
+		resolved, e := ResolveURI(base, variant.URI)
+		if e != nil {
+			return e
+		}
+		variant.URI = resolved
+	}
+	for _, rendition := range playlist.Renditions {
+		if rendition.URI == "" {
+			continue
+		}
+		resolved, e := ResolveURI(base, rendition.URI)
+		if e != nil {
+			return e
+		}
+		rendition.URI = resolved
+	}
+	return nil
+}
+
+func (playlist *MediaPlaylist) Resolve(base string) error {
+	// Keys and maps are shared between segments, each one is resolved once
+	keys := make(map[*Key]bool)
+	maps := make(map[*Map]bool)
+	for _, segment := range playlist.Segments {
+		resolved, e := ResolveURI(base, segment.URI)
+		if e != nil {
+			return e
+		}
+		segment.URI = resolved
+		if segment.Key != nil && !keys[segment.Key] {
+			if segment.Key.URI, e = ResolveURI(base, segment.Key.URI); e != nil {
+				return e
+			}
+			keys[segment.Key] = true
+		}
+		if segment.Map != nil && !maps[segment.Map] {
+			if segment.Map.URI, e = ResolveURI(base, segment.Map.URI); e != nil {
+				return e
+			}
+			maps[segment.Map] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package hls
+
+import "testing"
+
+func TestResolveURI(t *testing.T) {
+	for _, test := range []struct {
+		base, reference, expected string
+	}{
+		{"http://host.example/a/b/index.m3u8", "seg.ts", "http://host.example/a/b/seg.ts"},
+		{"http://host.example/a/b/index.m3u8?token=1", "seg.ts?n=2", "http://host.example/a/b/seg.ts?n=2"},
+		{"http://host.example/a/b/index.m3u8", "../c/seg.ts", "http://host.example/a/c/seg.ts"},
+		{"http://host.example/a/b/index.m3u8", "/root.ts", "http://host.example/root.ts"},
+		{"http://host.example/a/b/index.m3u8", "//cdn.example/seg.ts", "http://cdn.example/seg.ts"},
+		{"http://host.example/a/b/index.m3u8", "https://cdn.example/x/seg.ts", "https://cdn.example/x/seg.ts"},
+		{"http://host.example/a/b/index.m3u8", "skd://key-id", "skd://key-id"},
+	} {
+		resolved, e := ResolveURI(test.base, test.reference)
+		if e != nil || resolved != test.expected {
+			t.Errorf("ResolveURI(%q, %q) = %q, %v, want %q", test.base, test.reference, resolved, e, test.expected)
+		}
+	}
+	if _, e := ResolveURI("http://host.example/", "%zz"); e == nil {
+		t.Error("an invalid reference was resolved")
+	}
+}
+
+func TestResolveMediaPlaylist(t *testing.T) {
+	playlist, e := ParseString(testMediaPlaylist)
+	if e != nil {
+		t.Fatal(e)
+	}
+	media := playlist.(*MediaPlaylist)
+	if e := media.Resolve("http://host.example/a/b/index.m3u8?token=1"); e != nil {
+		t.Fatal(e)
+	}
+	for _, test := range []struct {
+		name, uri, expected string
+	}{
+		{"segment", media.Segments[0].URI, "http://host.example/a/b/main.mp4"},
+		{"relative segment", media.Segments[2].URI, "http://host.example/a/other/seg.m4s?n=1"},
+		// Keys and maps are shared, resolving them once per segment would nest them
+		{"map", media.Segments[1].Map.URI, "http://host.example/a/b/init.mp4"},
+		{"absolute key", media.Segments[1].Key.URI, "https://keys.example/key?id=1"},
+	} {
+		if test.uri != test.expected {
+			t.Errorf("%s: got %q, want %q", test.name, test.uri, test.expected)
+		}
+	}
+}
+
+func TestResolveMasterPlaylist(t *testing.T) {
+	playlist, e := ParseString(testMasterPlaylist + "#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID=\"cc\",NAME=\"cc\",INSTREAM-ID=\"CC1\"\n")
+	if e != nil {
+		t.Fatal(e)
+	}
+	master := playlist.(*MasterPlaylist)
+	if e := master.Resolve("https://host.example/master.m3u8"); e != nil {
+		t.Fatal(e)
+	}
+	for _, test := range []struct {
+		name, uri, expected string
+	}{
+		{"variant", master.Variants[0].URI, "https://host.example/720p/index.m3u8"},
+		{"absolute variant", master.Variants[1].URI, "http://cdn.example/360p.m3u8"},
+		{"rendition", master.Renditions[0].URI, "https://host.example/audio/en.m3u8"},
+		{"muxed rendition", master.Renditions[1].URI, ""},
+	} {
+		if test.uri != test.expected {
+			t.Errorf("%s: got %q, want %q", test.name, test.uri, test.expected)
+		}
+	}
+}
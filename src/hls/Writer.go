@@ -0,0 +1,164 @@
+package hls
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// attributeWriter builds an attribute list, empty values are left out
+type attributeWriter struct {
+	builder strings.Builder
+}
+
+func (writer *attributeWriter) raw(name, value string) {
+	if value == "" {
+		return
+	}
+	if writer.builder.Len() > 0 {
+		writer.builder.WriteByte(',')
+	}
+	writer.builder.WriteString(name)
+	writer.builder.WriteByte('=')
+	writer.builder.WriteString(value)
+}
+
+func (writer *attributeWriter) quoted(name, value string) {
+	if value != "" {
+		writer.raw(name, `"`+value+`"`)
+	}
+}
+
+func (writer *attributeWriter) flag(name string, value bool) {
+	if value {
+		writer.raw(name, "YES")
+	}
+}
+
+func (writer *attributeWriter) String() string {
+	return writer.builder.String()
+}
+
+func formatByteRange(byteRange *ByteRange) string {
+	return strconv.FormatInt(byteRange.Length, 10) + "@" + strconv.FormatInt(byteRange.Offset, 10)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (playlist *MasterPlaylist) Encode(w io.Writer) error {
+	buffer := bufio.NewWriter(w)
+	buffer.WriteString("#EXTM3U\n")
+	if playlist.Version > 0 {
+		fmt.Fprintf(buffer, "#EXT-X-VERSION:%d\n", playlist.Version)
+	}
+	if playlist.IndependentSegments {
+		buffer.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	for _, rendition := range playlist.Renditions {
+		var attributes attributeWriter
+		attributes.raw("TYPE", rendition.Type)
+		attributes.quoted("GROUP-ID", rendition.GroupID)
+		attributes.quoted("NAME", rendition.Name)
+		attributes.quoted("LANGUAGE", rendition.Language)
+		attributes.flag("DEFAULT", rendition.Default)
+		attributes.flag("AUTOSELECT", rendition.AutoSelect)
+		attributes.flag("FORCED", rendition.Forced)
+		attributes.quoted("INSTREAM-ID", rendition.InstreamID)
+		attributes.quoted("CHARACTERISTICS", rendition.Characteristics)
+		attributes.quoted("CHANNELS", rendition.Channels)
+		attributes.quoted("URI", rendition.URI)
+		fmt.Fprintf(buffer, "#EXT-X-MEDIA:%s\n", attributes.String())
+	}
+	for _, variant := range playlist.Variants {
+		var attributes attributeWriter
+		attributes.raw("BANDWIDTH", strconv.FormatInt(variant.Bandwidth, 10))
+		if variant.AverageBandwidth > 0 {
+			attributes.raw("AVERAGE-BANDWIDTH", strconv.FormatInt(variant.AverageBandwidth, 10))
+		}
+		attributes.quoted("CODECS", variant.Codecs)
+		if variant.Resolution.Width > 0 && variant.Resolution.Height > 0 {
+			attributes.raw("RESOLUTION", fmt.Sprintf("%dx%d", variant.Resolution.Width, variant.Resolution.Height))
+		}
+		if variant.FrameRate > 0 {
+			attributes.raw("FRAME-RATE", strconv.FormatFloat(variant.FrameRate, 'f', 3, 64))
+		}
+		attributes.quoted("AUDIO", variant.Audio)
+		attributes.quoted("VIDEO", variant.Video)
+		attributes.quoted("SUBTITLES", variant.Subtitles)
+		if variant.ClosedCaptions == "NONE" {
+			attributes.raw("CLOSED-CAPTIONS", "NONE")
+		} else {
+			attributes.quoted("CLOSED-CAPTIONS", variant.ClosedCaptions)
+		}
+		fmt.Fprintf(buffer, "#EXT-X-STREAM-INF:%s\n%s\n", attributes.String(), variant.URI)
+	}
+	return buffer.Flush()
+}
+
+func (playlist *MediaPlaylist) Encode(w io.Writer) error {
+	buffer := bufio.NewWriter(w)
+	buffer.WriteString("#EXTM3U\n")
+	if playlist.Version > 0 {
+		fmt.Fprintf(buffer, "#EXT-X-VERSION:%d\n", playlist.Version)
+	}
+	fmt.Fprintf(buffer, "#EXT-X-TARGETDURATION:%d\n", playlist.TargetDuration)
+	if playlist.MediaSequence != 0 {
+		fmt.Fprintf(buffer, "#EXT-X-MEDIA-SEQUENCE:%d\n", playlist.MediaSequence)
+	}
+	if playlist.DiscontinuitySequence != 0 {
+		fmt.Fprintf(buffer, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", playlist.DiscontinuitySequence)
+	}
+	if playlist.PlaylistType != "" {
+		fmt.Fprintf(buffer, "#EXT-X-PLAYLIST-TYPE:%s\n", playlist.PlaylistType)
+	}
+	if playlist.IndependentSegments {
+		buffer.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+
+	var key *Key
+	var initSection *Map
+	for _, segment := range playlist.Segments {
+		if segment.Discontinuity {
+			buffer.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if segment.Key != key {
+			if segment.Key == nil {
+				buffer.WriteString("#EXT-X-KEY:METHOD=NONE\n")
+			} else {
+				var attributes attributeWriter
+				attributes.raw("METHOD", segment.Key.Method)
+				attributes.quoted("URI", segment.Key.URI)
+				if len(segment.Key.IV) > 0 {
+					attributes.raw("IV", "0x"+hex.EncodeToString(segment.Key.IV))
+				}
+				attributes.quoted("KEYFORMAT", segment.Key.KeyFormat)
+				attributes.quoted("KEYFORMATVERSIONS", segment.Key.KeyFormatVersions)
+				fmt.Fprintf(buffer, "#EXT-X-KEY:%s\n", attributes.String())
+			}
+			key = segment.Key
+		}
+		if segment.Map != initSection && segment.Map != nil {
+			var attributes attributeWriter
+			attributes.quoted("URI", segment.Map.URI)
+			if segment.Map.ByteRange != nil {
+				attributes.quoted("BYTERANGE", formatByteRange(segment.Map.ByteRange))
+			}
+			fmt.Fprintf(buffer, "#EXT-X-MAP:%s\n", attributes.String())
+			initSection = segment.Map
+		}
+		fmt.Fprintf(buffer, "#EXTINF:%s,%s\n", formatFloat(segment.Duration), segment.Title)
+		if segment.ByteRange != nil {
+			fmt.Fprintf(buffer, "#EXT-X-BYTERANGE:%s\n", formatByteRange(segment.ByteRange))
+		}
+		buffer.WriteString(segment.URI + "\n")
+	}
+	if playlist.EndList {
+		buffer.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return buffer.Flush()
+}
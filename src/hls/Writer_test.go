@@ -0,0 +1,87 @@
+package hls
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name, content string
+	}{
+		{"master", testMasterPlaylist},
+		{"media", testMediaPlaylist},
+		{"media with maps and keys", `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-DISCONTINUITY-SEQUENCE:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://k",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXTINF:4,
+a.m4s
+#EXT-X-KEY:METHOD=AES-128,URI="k.bin"
+#EXT-X-MAP:URI="init2.mp4",BYTERANGE="10@5"
+#EXTINF:3.25,title, with a comma
+b.m4s
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4,
+c.m4s
+`},
+	} {
+		playlist, e := ParseString(test.content)
+		if e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		var encoded bytes.Buffer
+		if e := playlist.Encode(&encoded); e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		parsed, e := ParseString(encoded.String())
+		if e != nil {
+			t.Errorf("%s: parsing the encoded playlist: %v\n%s", test.name, e, encoded.String())
+			continue
+		}
+		if !reflect.DeepEqual(parsed, playlist) {
+			t.Errorf("%s: the encoded playlist parses differently:\n%s", test.name, encoded.String())
+		}
+		// Encoding is stable once the playlist went through it
+		var again bytes.Buffer
+		parsed.Encode(&again)
+		if again.String() != encoded.String() {
+			t.Errorf("%s: got\n%s\nthen\n%s", test.name, encoded.String(), again.String())
+		}
+	}
+}
+
+func TestEncodeMediaPlaylist(t *testing.T) {
+	key := &Key{Method: "AES-128", URI: "k.bin", IV: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}}
+	playlist := &MediaPlaylist{
+		TargetDuration: 4,
+		EndList:        true,
+		Segments: []*Segment{
+			{URI: "a.ts", Duration: 4, Key: key, ByteRange: &ByteRange{100, 0}},
+			{URI: "a.ts", Duration: 2.5, Key: key, ByteRange: &ByteRange{50, 100}},
+		},
+	}
+	var encoded bytes.Buffer
+	if e := playlist.Encode(&encoded); e != nil {
+		t.Fatal(e)
+	}
+	expected := `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-KEY:METHOD=AES-128,URI="k.bin",IV=0x00000000000000000000000000000001
+#EXTINF:4,
+#EXT-X-BYTERANGE:100@0
+a.ts
+#EXTINF:2.5,
+#EXT-X-BYTERANGE:50@100
+a.ts
+#EXT-X-ENDLIST
+`
+	if encoded.String() != expected {
+		t.Errorf("got\n%s\nwant\n%s", encoded.String(), expected)
+	}
+}
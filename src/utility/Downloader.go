@@ -5,30 +5,42 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-utils/src/concurrency"
+	"go-utils/src/hls"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
 )
 
+// fetchGroup lets concurrent FetchContent calls for the same url share one request
+var fetchGroup = concurrency.NewGroup(0)
+
 func FetchContent(url string) (string, error) {
-	response, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	bodyBytes, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return "", err
-	}
+	content, err, _ := fetchGroup.Do(url, func() (interface{}, error) {
+		response, err := http.Get(url)
+		if err != nil {
+			return "", err
+		}
+		bodyBytes, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return "", err
+		}
 
-	if err = response.Body.Close(); err != nil {
+		if err = response.Body.Close(); err != nil {
+			return "", err
+		}
+		return string(bodyBytes), nil
+	})
+	if err != nil {
 		return "", err
 	}
-	return string(bodyBytes), nil
+	return content.(string), nil
 }
 
 func DownloadFile(from string, to string) error {
@@ -55,87 +67,192 @@ func DownloadFile(from string, to string) error {
 	return nil
 }
 
-func removeHLSSuffix(indexFile string) error {
-	pattern := regexp.MustCompile(`^(.*.ts)\?.*$`)
-	content, e := ioutil.ReadFile(indexFile)
+// CompareHLS returns the segments listed in indexFile which are missing from directory, nested playlists
+// of a master playlist included. When directory holds the manifest of a download, segments of another size
+// than recorded count as missing too.
+func CompareHLS(indexFile string, directory string) ([]string, error) {
+	sizes := make(map[string]int64)
+	if manifest, e := loadHLSManifest(directory); e == nil {
+		for i := range manifest.Segments {
+			if manifest.Segments[i].Complete {
+				sizes[manifest.Segments[i].File] = manifest.Segments[i].Size
+			}
+		}
+	}
+	return compareHLS(indexFile, directory, "", sizes)
+}
+
+// compareHLS checks the local files of indexFile, which lies in the prefix subdirectory of directory
+func compareHLS(indexFile, directory, prefix string, sizes map[string]int64) ([]string, error) {
+	file, e := os.Open(indexFile)
 	if e != nil {
-		return e
+		return nil, e
+	}
+	playlist, e := hls.Parse(file)
+	file.Close()
+	if e != nil {
+		return nil, e
 	}
-	lines := strings.Split(string(content), "\n")
 
-	var builder strings.Builder
-	for i := range lines {
-		if pattern.MatchString(lines[i]) {
-			if _, e := builder.WriteString(pattern.FindStringSubmatch(lines[i])[1] + "\n"); e != nil {
-				return e
+	missing := make([]string, 0)
+	files := make([]string, 0)
+	switch playlist := playlist.(type) {
+	case *hls.MasterPlaylist:
+		for _, variant := range playlist.Variants {
+			if strings.Contains(variant.URI, "://") {
+				continue
 			}
-		} else {
-			if _, e := builder.WriteString(lines[i] + "\n"); e != nil {
-				return e
+			partition, e := compareHLS(filepath.Join(filepath.Dir(indexFile), filepath.FromSlash(variant.URI)),
+				directory, path.Join(prefix, path.Dir(variant.URI)), sizes)
+			if e != nil {
+				return nil, e
 			}
+			missing = append(missing, partition...)
+		}
+	case *hls.MediaPlaylist:
+		var initSection *hls.Map
+		for _, segment := range playlist.Segments {
+			if segment.Map != nil && segment.Map != initSection {
+				files = append(files, segment.Map.URI)
+				initSection = segment.Map
+			}
+			files = append(files, segment.URI)
 		}
 	}
-	if e := ioutil.WriteFile(indexFile, []byte(builder.String()), 0644); e != nil {
-		return e
+	for i := range files {
+		if strings.Contains(files[i], "://") {
+			continue
+		}
+		name := path.Join(prefix, files[i])
+		info, e := os.Stat(filepath.Join(directory, filepath.FromSlash(name)))
+		if e != nil {
+			missing = append(missing, name)
+		} else if size, exist := sizes[name]; exist && size != info.Size() {
+			missing = append(missing, name)
+		}
 	}
-	return nil
+	return missing, nil
 }
 
-func CompareHLS(indexFile string, directory string) error {
-	fileBytes, e := ioutil.ReadFile(indexFile)
+// fetchPlaylist downloads the playlist at url with client, any answer but 200 OK is an error
+func fetchPlaylist(client *http.Client, url string) (string, error) {
+	response, e := client.Get(url)
 	if e != nil {
-		return e
+		return "", e
 	}
-	lines := strings.Split(string(fileBytes), "\n")
-	for i := range lines {
-		if strings.HasSuffix(lines[i], ".ts") {
-			if !FileExist(directory + lines[i]) {
-				fmt.Println(lines[i])
-			}
-		}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("hls: playlist %s: unexpected status %s", url, response.Status)
 	}
-	return nil
+	content, e := ioutil.ReadAll(response.Body)
+	if e != nil {
+		return "", e
+	}
+	return string(content), nil
 }
 
-func downloadHLSIndex(url, destDir string) ([]string, error) {
-	metaInformationPattern := regexp.MustCompile(`^(https?://.*/)(.*\.m3u8)\??(.*)$`)
-	tlsFilePattern := regexp.MustCompile(`^(.*\.ts)\??(.*)$`)
-	cryptoPattern := regexp.MustCompile(`"(.*\.ts)"`)
-	indexPattern := regexp.MustCompile(`^(.*\.m3u8)\??(.*)$`)
-
-	metaInformation := metaInformationPattern.FindStringSubmatch(url)
-	path := metaInformation[1]
-	filename := metaInformation[2]
-
-	result := make([]string, 0)
-	if err := DownloadFile(url, destDir); err != nil {
-		return nil, err
+// downloadHLSIndex saves the playlist at url as destDir+prefix+name, rewritten to point at the files
+// its segments will be saved as, and returns the segments to download. Of a master playlist only the
+// variant picked by options is followed, along with the renditions options asks for, each one into its
+// own subdirectory.
+func downloadHLSIndex(url, destDir, prefix, name string, options HLSOptions) ([]hlsSegment, error) {
+	content, e := fetchPlaylist(options.Client, url)
+	if e != nil {
+		return nil, e
 	}
-	content, err := ioutil.ReadFile(destDir + filename)
-	if err != nil {
-		return nil, err
+	playlist, e := hls.ParseString(content)
+	if e != nil {
+		return nil, fmt.Errorf("%s: %v", url, e)
+	}
+	if e := playlist.Resolve(url); e != nil {
+		return nil, e
 	}
 
-	lines := strings.Split(string(content), "\n")
-	for i := range lines {
-		line := strings.TrimSpace(lines[i])
-		if cryptoPattern.MatchString(line) {
-			result = append(result, cryptoPattern.FindStringSubmatch(line)[1])
-		} else if tlsFilePattern.MatchString(line) {
-			result = append(result, line)
-		} else if indexPattern.MatchString(line) {
-			matches := indexPattern.FindStringSubmatch(line)
-			partition, err := downloadHLSIndex(path+matches[1]+"?"+matches[2], destDir)
-			if err != nil {
-				return nil, err
+	result := make([]hlsSegment, 0)
+	// follow downloads the media playlist at uri into directory and returns its local URI
+	follow := func(uri, directory string) (string, error) {
+		if e := os.MkdirAll(destDir+prefix+directory, os.ModePerm); e != nil {
+			return "", e
+		}
+		local := segmentFileName(uri)
+		partition, e := downloadHLSIndex(uri, destDir, prefix+directory, local, options)
+		if e != nil {
+			return "", e
+		}
+		result = append(result, partition...)
+		return directory + local, nil
+	}
+	switch playlist := playlist.(type) {
+	case *hls.MasterPlaylist:
+		variant := playlist.Select(options.Variant)
+		if variant == nil {
+			return nil, fmt.Errorf("%s: no variant matches", url)
+		}
+		if variant.URI, e = follow(variant.URI, "stream/"); e != nil {
+			return nil, e
+		}
+
+		// The local copy only lists what has been downloaded, and the renditions muxed into the variant
+		renditions := make([]*hls.Rendition, 0)
+		for _, renditionType := range []string{"AUDIO", "VIDEO", "SUBTITLES", "CLOSED-CAPTIONS"} {
+			kept := 0
+			for i, rendition := range playlist.RenditionsOf(variant, renditionType) {
+				if rendition.URI != "" {
+					if !options.wantRendition(rendition) {
+						continue
+					}
+					directory := fmt.Sprintf("%s%d/", strings.ToLower(renditionType), i)
+					if rendition.URI, e = follow(rendition.URI, directory); e != nil {
+						return nil, e
+					}
+				}
+				renditions = append(renditions, rendition)
+				kept++
+			}
+			// CLOSED-CAPTIONS=NONE has no group behind it and stays as written
+			if kept == 0 && variant.Group(renditionType) != "" {
+				variant.SetGroup(renditionType, "")
 			}
-			for j := range partition {
-				result = append(result, partition[j])
+		}
+		playlist.Variants = []*hls.Variant{variant}
+		playlist.Renditions = renditions
+	case *hls.MediaPlaylist:
+		// Initialization sections are saved as they are, only media segments are decrypted.
+		// Files are numbered in the order of the playlist, result holds none of another one yet.
+		maps := make(map[*hls.Map]bool)
+		for _, segment := range playlist.Segments {
+			if segment.Map != nil && !maps[segment.Map] {
+				local := localSegmentName(len(result), segment.Map.URI)
+				initSection := hlsSegment{URI: segment.Map.URI, File: prefix + local}
+				if segment.Map.ByteRange != nil {
+					initSection.Offset, initSection.Length = segment.Map.ByteRange.Offset, segment.Map.ByteRange.Length
+				}
+				result = append(result, initSection)
+				segment.Map.URI, segment.Map.ByteRange = local, nil
+				maps[segment.Map] = true
+			}
+
+			local := localSegmentName(len(result), segment.URI)
+			media := hlsSegment{URI: segment.URI, File: prefix + local, Sequence: segment.Sequence, Key: newHLSKey(segment.Key)}
+			if segment.ByteRange != nil {
+				media.Offset, media.Length = segment.ByteRange.Offset, segment.ByteRange.Length
 			}
+			result = append(result, media)
+			// The local copy refers to the saved files, which are whole and decrypted
+			segment.URI, segment.ByteRange, segment.Key = local, nil, nil
 		}
 	}
-	if e := removeHLSSuffix(destDir + filename); e != nil {
-		return result, e
+
+	file, e := os.Create(destDir + prefix + name)
+	if e != nil {
+		return nil, e
+	}
+	if e := playlist.Encode(file); e != nil {
+		file.Close()
+		return nil, e
+	}
+	if e := file.Close(); e != nil {
+		return nil, e
 	}
 	return result, nil
 }
@@ -155,27 +272,31 @@ func downloadHLSIndex(url, destDir string) ([]string, error) {
 //}
 
 func DownloadHLS(url string, destDir string) error {
-	if e := os.Mkdir(destDir+"_go_temp", os.ModePerm); e != nil {
+	return DownloadHLSWithOptions(url, destDir, HLSOptions{})
+}
+
+// DownloadHLSWithOptions saves the playlist at url and all of its segments into destDir+"_go_temp",
+// segments are downloaded in parallel and the ones still failing after their retries are listed in an *HLSDownloadError.
+// Running it again for the same url resumes the download, only segments missing or truncated are fetched.
+func DownloadHLSWithOptions(url string, destDir string, options HLSOptions) error {
+	if e := os.MkdirAll(destDir+"_go_temp", os.ModePerm); e != nil {
 		return e
 	}
-	pattern := regexp.MustCompile(`^(https?://.*/)(.*\.m3u8)\??(.*)$`)
-	meta := pattern.FindStringSubmatch(url)
-	chunkFiles, e := downloadHLSIndex(url, destDir+"_go_temp/")
-	if e != nil {
-		return e
+	options = options.withDefaults()
+	manifest, e := loadHLSManifest(destDir + "_go_temp/")
+	if e != nil || manifest.URL != url {
+		chunkFiles, e := downloadHLSIndex(url, destDir+"_go_temp/", "", segmentFileName(url), options)
+		if e != nil {
+			return e
+		}
+		manifest = newHLSManifest(url, chunkFiles)
+		if e := manifest.save(destDir + "_go_temp/"); e != nil {
+			return e
+		}
 	}
 
-	for i := range chunkFiles {
-		fmt.Printf("Downloading: %s %d/%d\n", chunkFiles[i], i, len(chunkFiles)-1)
-		for j := 0; j < 3; j++ {
-			if j > 0 {
-				fmt.Printf("Retry Downloading: %s %d/%d\n", chunkFiles[i], i, len(chunkFiles)-1)
-			}
-			err := DownloadFile(meta[1]+chunkFiles[i], destDir+"_go_temp/")
-			if err == nil {
-				break
-			}
-		}
+	if e := downloadSegments(manifest, destDir+"_go_temp/", options); e != nil {
+		return e
 	}
 	//if e := mergeHLS(destDir + "_go_temp/" + meta[2]); e != nil {
 	//	return e
@@ -0,0 +1,128 @@
+package utility
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-utils/src/concurrency"
+	"go-utils/src/hls"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+var ErrUnsupportedEncryption = errors.New("hls: unsupported encryption method")
+
+// hlsKey is the #EXT-X-KEY in effect for a segment, URI is absolute and IV empty when the tag has none
+type hlsKey struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"`
+	IV     []byte `json:"iv,omitempty"`
+	// Format is the KEYFORMAT, empty for identity
+	Format string `json:"format,omitempty"`
+}
+
+func newHLSKey(key *hls.Key) *hlsKey {
+	if key == nil {
+		return nil
+	}
+	format := key.KeyFormat
+	if format == "identity" {
+		format = ""
+	}
+	return &hlsKey{Method: key.Method, URI: key.URI, IV: key.IV, Format: format}
+}
+
+// hlsKeyCache downloads every key once, however many segments share it
+type hlsKeyCache struct {
+	client *http.Client
+	group  concurrency.Group
+	mutex  sync.Mutex
+	keys   map[string][]byte
+}
+
+func newHLSKeyCache(client *http.Client) *hlsKeyCache {
+	return &hlsKeyCache{client: client, keys: make(map[string][]byte)}
+}
+
+func (cache *hlsKeyCache) get(uri string) ([]byte, error) {
+	cache.mutex.Lock()
+	key, exist := cache.keys[uri]
+	cache.mutex.Unlock()
+	if exist {
+		return key, nil
+	}
+
+	value, e, _ := cache.group.Do(uri, func() (interface{}, error) {
+		response, e := cache.client.Get(uri)
+		if e != nil {
+			return nil, e
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("hls: key %s: unexpected status %s", uri, response.Status)
+		}
+		key, e := ioutil.ReadAll(response.Body)
+		if e != nil {
+			return nil, e
+		}
+		if len(key) != aes.BlockSize {
+			return nil, fmt.Errorf("hls: key %s has %d bytes", uri, len(key))
+		}
+		cache.mutex.Lock()
+		cache.keys[uri] = key
+		cache.mutex.Unlock()
+		return key, nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return value.([]byte), nil
+}
+
+// decrypter returns the function decrypting a segment of the given media sequence number
+func (cache *hlsKeyCache) decrypter(key *hlsKey, sequence int64) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		// Keys of other formats are only known to DRM systems
+		if key.Method != "AES-128" && key.Method != "SAMPLE-AES" || key.Format != "" {
+			return nil, ErrUnsupportedEncryption
+		}
+		secret, e := cache.get(key.URI)
+		if e != nil {
+			return nil, e
+		}
+		iv := key.IV
+		if len(iv) == 0 {
+			iv = make([]byte, aes.BlockSize)
+			binary.BigEndian.PutUint64(iv[aes.BlockSize-8:], uint64(sequence))
+		}
+		if key.Method == "SAMPLE-AES" {
+			return decryptSampleAES(secret, iv, data)
+		}
+		return decryptAES128(secret, iv, data)
+	}
+}
+
+// decryptAES128 decrypts data in place with AES-128-CBC and strips the PKCS7 padding
+func decryptAES128(key, iv, data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("hls: encrypted segment is not a whole number of blocks")
+	}
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("hls: invalid padding, wrong key or IV")
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("hls: invalid padding, wrong key or IV")
+		}
+	}
+	return data[:len(data)-padding], nil
+}
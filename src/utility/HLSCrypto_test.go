@@ -0,0 +1,530 @@
+package utility
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var (
+	testKey = []byte("0123456789abcdef")
+	testIV  = []byte("fedcba9876543210")
+)
+
+// encryptAES128 encrypts a whole segment the way AES-128 streams are, with PKCS7 padding
+func encryptAES128(key, iv, plain []byte) []byte {
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return data
+}
+
+func sequenceIV(sequence uint64) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	return iv
+}
+
+// serveHLS serves files by path and counts the requests of the key
+func serveHLS(files map[string][]byte, keyHits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v/keys/k.bin" {
+			atomic.AddInt32(keyHits, 1)
+		}
+		content, exist := files[r.URL.Path]
+		if !exist {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+}
+
+func TestDownloadHLSDecryptsAES128(t *testing.T) {
+	var keyHits int32
+	server := serveHLS(map[string][]byte{
+		"/v/index.m3u8": []byte(`#EXTM3U
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-KEY:METHOD=AES-128,URI="keys/k.bin",IV=0x66656463626139383736353433323130
+#EXTINF:4,
+a.ts
+#EXTINF:4,
+b.ts
+#EXT-X-KEY:METHOD=AES-128,URI="keys/k.bin"
+#EXTINF:4,
+c.ts
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4,
+d.ts
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="keys/k.bin",KEYFORMAT="identity"
+#EXTINF:4,
+e.mp4
+#EXT-X-ENDLIST
+`),
+		"/v/keys/k.bin": testKey,
+		"/v/a.ts":       encryptAES128(testKey, testIV, []byte("segment a")),
+		"/v/b.ts":       encryptAES128(testKey, testIV, []byte("segment b, longer than one block")),
+		"/v/c.ts":       encryptAES128(testKey, sequenceIV(12), []byte("segment c")),
+		"/v/d.ts":       []byte("plain d"),
+		"/v/e.mp4":      []byte("fragmented MP4 encrypted with cbcs"),
+	}, &keyHits)
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	e = DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{Concurrency: 3})
+	if failure, ok := e.(*HLSDownloadError); !ok || len(failure.Failed) != 1 || failure.Failed[0].Err != ErrUnsupportedEncryption {
+		t.Fatalf("got %v, want the SAMPLE-AES MP4 segment to fail with ErrUnsupportedEncryption", e)
+	}
+	temp := filepath.Join(dir, "out_go_temp")
+	for name, expected := range map[string]string{
+		"00000_a.ts": "segment a",
+		"00001_b.ts": "segment b, longer than one block",
+		"00002_c.ts": "segment c",
+		"00003_d.ts": "plain d",
+	} {
+		if content, _ := ioutil.ReadFile(filepath.Join(temp, name)); string(content) != expected {
+			t.Errorf("%s: got %q, want %q", name, content, expected)
+		}
+	}
+	if keyHits != 1 {
+		t.Errorf("key downloaded %d times, want once", keyHits)
+	}
+	if index, _ := ioutil.ReadFile(filepath.Join(temp, "index.m3u8")); strings.Contains(string(index), "#EXT-X-KEY") {
+		t.Errorf("local playlist of decrypted segments still has keys:\n%s", index)
+	}
+}
+
+func TestDownloadHLSKeyFormats(t *testing.T) {
+	var keyHits int32
+	server := serveHLS(map[string][]byte{
+		"/v/index.m3u8": []byte(`#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-KEY:METHOD=AES-128,URI="keys/k.bin",IV=0x66656463626139383736353433323130,KEYFORMAT="identity"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery"
+#EXTINF:4,
+a.ts
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery"
+#EXT-X-KEY:METHOD=AES-128,URI="keys/k.bin",IV=0x66656463626139383736353433323130
+#EXTINF:4,
+b.ts
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery"
+#EXTINF:4,
+c.ts
+#EXT-X-ENDLIST
+`),
+		"/v/keys/k.bin": testKey,
+		"/v/a.ts":       encryptAES128(testKey, testIV, []byte("segment a")),
+		"/v/b.ts":       encryptAES128(testKey, testIV, []byte("segment b")),
+		"/v/c.ts":       encryptAES128(testKey, testIV, []byte("segment c")),
+	}, &keyHits)
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	// The identity key of a segment is used whatever its place, a segment with only a DRM key fails
+	e = DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{})
+	if failure, ok := e.(*HLSDownloadError); !ok || len(failure.Failed) != 1 ||
+		!strings.HasSuffix(failure.Failed[0].Segment, "c.ts") || failure.Failed[0].Err != ErrUnsupportedEncryption {
+		t.Fatalf("got %v, want c.ts to fail with ErrUnsupportedEncryption", e)
+	}
+	temp := filepath.Join(dir, "out_go_temp")
+	for name, expected := range map[string]string{"00000_a.ts": "segment a", "00001_b.ts": "segment b"} {
+		if content, _ := ioutil.ReadFile(filepath.Join(temp, name)); string(content) != expected {
+			t.Errorf("%s: got %q, want %q", name, content, expected)
+		}
+	}
+	if _, e := os.Stat(filepath.Join(temp, "00002_c.ts")); !os.IsNotExist(e) {
+		t.Errorf("the segment encrypted for a DRM system was written: %v", e)
+	}
+}
+
+func TestDecryptAES128WrongKey(t *testing.T) {
+	data := encryptAES128(testKey, testIV, []byte("segment"))
+	if _, e := decryptAES128([]byte("fedcba9876543210"), testIV, data); e == nil {
+		t.Error("decrypting with the wrong key succeeded")
+	}
+	if _, e := decryptAES128(testKey, testIV, data[:5]); e == nil {
+		t.Error("decrypting a partial block succeeded")
+	}
+}
+
+const (
+	testVideoPID = 0x100
+	testAACPID   = 0x101
+	testAC3PID   = 0x102
+	testEAC3PID  = 0x103
+	testPMTPID   = 0x1000
+)
+
+// sampleAESFixture is a transport stream in the clear and encrypted with SAMPLE-AES
+type sampleAESFixture struct {
+	types     map[int]byte
+	clear     map[int][]byte
+	encrypted []byte
+}
+
+// newSampleAESFixture muxes a few PES packets of H.264, AAC, AC-3 and E-AC-3, with slices whose size
+// changes once decrypted, both ways, as their emulation prevention bytes differ
+func newSampleAESFixture(random *rand.Rand, pesCount int) *sampleAESFixture {
+	block, _ := aes.NewCipher(testKey)
+	fixture := &sampleAESFixture{
+		types: map[int]byte{testVideoPID: 0x1b, testAACPID: 0x0f, testAC3PID: 0x81, testEAC3PID: 0x87},
+		clear: make(map[int][]byte),
+	}
+	encryptedTypes := map[int]byte{testVideoPID: 0xdb, testAACPID: 0xcf, testAC3PID: 0xc1, testEAC3PID: 0xc2}
+	pids := []int{testVideoPID, testAACPID, testAC3PID, testEAC3PID}
+	fixture.encrypted = append(muxPAT(), muxPMT(pids, encryptedTypes)...)
+	counters := make(map[int]byte)
+	for i := 0; i < pesCount; i++ {
+		for _, pid := range pids {
+			var clear, encrypted []byte
+			switch pid {
+			case testVideoPID:
+				clear, encrypted = testAccessUnit(random, block, i%2 == 0)
+			case testAACPID:
+				clear, encrypted = testAudioFrames(random, block, testADTSFrame, []int{10, 100, 333, 1000})
+			case testAC3PID:
+				clear, encrypted = testAudioFrames(random, block, testAC3Frame, []int{0, 1, 3})
+			default:
+				clear, encrypted = testAudioFrames(random, block, testEAC3Frame, []int{99, 20})
+			}
+			fixture.clear[pid] = append(fixture.clear[pid], clear...)
+			counter := counters[pid]
+			fixture.encrypted = append(fixture.encrypted, muxPES(pid, encrypted, pid == testVideoPID, &counter)...)
+			counters[pid] = counter
+		}
+	}
+	return fixture
+}
+
+// testAccessUnit returns an access unit of H.264 in the clear and encrypted. Its large slice grows
+// once decrypted when grow is set and shrinks otherwise, by more than a packet.
+func testAccessUnit(random *rand.Rand, block cipher.Block, grow bool) ([]byte, []byte) {
+	slice := func(header byte, size int) []byte {
+		nal := make([]byte, size)
+		random.Read(nal)
+		nal[0] = header
+		return nal
+	}
+	large := slice(0x65, 20000)
+	chain := append([]byte(nil), testIV...)
+	for offset := 32; len(large)-offset > aes.BlockSize; offset += 10 * aes.BlockSize {
+		if grow {
+			// Mostly zeros, which need emulation prevention bytes in the clear but not once encrypted
+			for i := offset; i < offset+aes.BlockSize; i++ {
+				if random.Intn(4) != 0 {
+					large[i] = 0
+				}
+			}
+		} else {
+			// Encrypted to zeros, which need emulation prevention bytes once encrypted only
+			block.Decrypt(large[offset:offset+aes.BlockSize], make([]byte, aes.BlockSize))
+			for i := range chain {
+				large[offset+i] ^= chain[i]
+				chain[i] = 0
+			}
+		}
+	}
+
+	var clear, encrypted []byte
+	for _, unit := range [][]byte{{0x09, 0xf0}, slice(0x67, 60), large, slice(0x41, 40), slice(0x41, 700)} {
+		nal := append([]byte(nil), unit...)
+		nal[len(nal)-1] |= 1
+		start := []byte{0, 0, 0, 1}
+		clear = appendEmulationPrevention(append(clear, start...), nal)
+		if unitType := nal[0] & 0x1f; len(nal) > 48 && (unitType == 1 || unitType == 5) {
+			mode := cipher.NewCBCEncrypter(block, testIV)
+			for offset := 32; len(nal)-offset > aes.BlockSize; offset += 10 * aes.BlockSize {
+				mode.CryptBlocks(nal[offset:offset+aes.BlockSize], nal[offset:offset+aes.BlockSize])
+			}
+		}
+		encrypted = appendEmulationPrevention(append(encrypted, start...), nal)
+	}
+	return clear, encrypted
+}
+
+// testAudioFrames returns the frames made by frame for each of the parameters, in the clear and encrypted
+func testAudioFrames(random *rand.Rand, block cipher.Block, frame func(*rand.Rand, int) ([]byte, int), parameters []int) ([]byte, []byte) {
+	var clear, encrypted []byte
+	for _, parameter := range parameters {
+		content, header := frame(random, parameter)
+		clear = append(clear, content...)
+		sample := content[header:]
+		if n := (len(sample) - 16) / aes.BlockSize * aes.BlockSize; n > 0 {
+			cipher.NewCBCEncrypter(block, testIV).CryptBlocks(sample[16:16+n], sample[16:16+n])
+		}
+		encrypted = append(encrypted, content...)
+	}
+	return clear, encrypted
+}
+
+// testADTSFrame returns an ADTS frame of size bytes of raw data, with a CRC when size is even
+func testADTSFrame(random *rand.Rand, size int) ([]byte, int) {
+	header := []byte{0xff, 0xf1, 0x50, 0x80, 0, 0x1f, 0xfc}
+	if size%2 == 0 {
+		header = append(header, 0, 0)
+		header[1] = 0xf0
+	}
+	length := len(header) + size
+	header[3] |= byte(length >> 11 & 3)
+	header[4] = byte(length >> 3)
+	header[5] |= byte(length&7) << 5
+	raw := make([]byte, size)
+	random.Read(raw)
+	return append(header, raw...), len(header)
+}
+
+// testAC3Frame returns an AC-3 frame at 48 kHz, or 44.1 kHz with an odd code, of the given frame size code
+func testAC3Frame(random *rand.Rand, code int) ([]byte, int) {
+	sampleRate := byte(0)
+	if code%2 == 1 {
+		sampleRate = 1
+	}
+	frame := []byte{0x0b, 0x77, 0, 0, sampleRate<<6 | byte(code), 8 << 3}
+	_, size := ac3Frame(frame)
+	raw := make([]byte, size-len(frame))
+	random.Read(raw)
+	return append(frame, raw...), 0
+}
+
+// testEAC3Frame returns an E-AC-3 frame of words+1 words
+func testEAC3Frame(random *rand.Rand, words int) ([]byte, int) {
+	frame := []byte{0x0b, 0x77, byte(words >> 8), byte(words), 0, 16 << 3}
+	raw := make([]byte, (words+1)*2-len(frame))
+	random.Read(raw)
+	return append(frame, raw...), 0
+}
+
+func muxPSI(pid int, section []byte) []byte {
+	crc := mpegCRC32(section)
+	packet := append([]byte{tsSyncByte, 0x40 | byte(pid>>8), byte(pid), 0x10, 0}, section...)
+	packet = append(packet, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	return append(packet, bytes.Repeat([]byte{0xff}, tsPacketSize-len(packet))...)
+}
+
+func muxPAT() []byte {
+	return muxPSI(0, []byte{0x00, 0xb0, 13, 0, 1, 0xc1, 0, 0, 0, 1, 0xe0 | testPMTPID>>8, testPMTPID & 0xff})
+}
+
+func muxPMT(pids []int, types map[int]byte) []byte {
+	section := []byte{0x02, 0xb0, byte(13 + 5*len(pids)), 0, 1, 0xc1, 0, 0, 0xe0 | testVideoPID>>8, testVideoPID & 0xff, 0xf0, 0}
+	for _, pid := range pids {
+		section = append(section, types[pid], 0xe0|byte(pid>>8), byte(pid), 0xf0, 0)
+	}
+	return muxPSI(testPMTPID, section)
+}
+
+// muxPES packs a PES packet of content into packets, the first one with a PCR when pcr is set
+func muxPES(pid int, content []byte, pcr bool, counter *byte) []byte {
+	length := 0
+	streamID := byte(0xe0)
+	if pid != testVideoPID {
+		length, streamID = 8+len(content), 0xc0
+	}
+	pes := append([]byte{0, 0, 1, streamID, byte(length >> 8), byte(length), 0x80, 0x80, 5, 0x21, 0, 1, 0, 1}, content...)
+	var output []byte
+	for first := true; len(pes) > 0; first = false {
+		header := []byte{tsSyncByte, byte(pid >> 8), byte(pid), 0x10 | *counter}
+		*counter = (*counter + 1) & 0x0f
+		if first {
+			header[1] |= 0x40
+		}
+		if first && pcr {
+			header[3] |= 0x20
+			header = append(header, 7, 0x50, 0, 0, 0, 1, 0x7e, 0)
+		}
+		if n := tsPacketSize - len(header) - len(pes); n > 0 {
+			if header[3]&0x20 == 0 {
+				header[3] |= 0x20
+				header = append(header, 0)
+				if n--; n > 0 {
+					header = append(header, 0)
+					header[4]++
+					n--
+				}
+			}
+			header = append(header, bytes.Repeat([]byte{0xff}, n)...)
+			header[4] += byte(n)
+		}
+		size := tsPacketSize - len(header)
+		output = append(output, append(header, pes[:size]...)...)
+		pes = pes[size:]
+	}
+	return output
+}
+
+// demuxTS checks the packets, program tables and PES packets of a transport stream
+// and returns the stream types and the elementary streams
+func demuxTS(t *testing.T, data []byte) (map[int]byte, map[int][]byte) {
+	if len(data)%tsPacketSize != 0 {
+		t.Fatalf("stream of %d bytes is not made of packets", len(data))
+	}
+	types := make(map[int]byte)
+	streams := make(map[int][]byte)
+	current := make(map[int][]byte)
+	counters := make(map[int]byte)
+	finish := func(pid int) {
+		pes := current[pid]
+		if pes == nil {
+			return
+		}
+		if len(pes) < 9 || !bytes.Equal(pes[:3], []byte{0, 0, 1}) {
+			t.Fatalf("PID %#x: broken PES packet", pid)
+		}
+		if length := int(pes[4])<<8 | int(pes[5]); length != 0 && length != len(pes)-6 {
+			t.Fatalf("PID %#x: PES packet of %d bytes has length %d", pid, len(pes)-6, length)
+		}
+		streams[pid] = append(streams[pid], pes[9+int(pes[8]):]...)
+	}
+	pmt := -1
+	for i := 0; i < len(data); i += tsPacketSize {
+		packet := data[i : i+tsPacketSize]
+		if packet[0] != tsSyncByte {
+			t.Fatalf("packet %d has lost sync", i/tsPacketSize)
+		}
+		pid := tsPID(packet)
+		offset, e := tsPayloadOffset(packet)
+		if e != nil {
+			t.Fatalf("packet %d: %v", i/tsPacketSize, e)
+		}
+		if offset == tsPacketSize {
+			continue
+		}
+		counter := packet[3] & 0x0f
+		if last, seen := counters[pid]; seen && counter != (last+1)&0x0f {
+			t.Fatalf("packet %d of PID %#x has continuity counter %d after %d", i/tsPacketSize, pid, counter, last)
+		}
+		counters[pid] = counter
+		start := packet[1]&0x40 != 0
+		switch {
+		case pid == 0:
+			section := packet[offset+1+int(packet[offset]):]
+			pmt = int(section[10]&0x1f)<<8 | int(section[11])
+		case pid == pmt:
+			section, _ := psiSection(packet[offset:])
+			if mpegCRC32(section) != 0 {
+				t.Fatal("PMT has a wrong CRC")
+			}
+			for j := 12; j+5 <= len(section)-4; j += 5 {
+				types[int(section[j+1]&0x1f)<<8|int(section[j+2])] = section[j]
+			}
+		default:
+			if start {
+				finish(pid)
+				current[pid] = nil
+			}
+			current[pid] = append(current[pid], packet[offset:]...)
+		}
+	}
+	for pid := range current {
+		finish(pid)
+	}
+	return types, streams
+}
+
+func TestDownloadHLSDecryptsSampleAES(t *testing.T) {
+	fixture := newSampleAESFixture(rand.New(rand.NewSource(1)), 3)
+	var keyHits int32
+	server := serveHLS(map[string][]byte{
+		"/v/index.m3u8": []byte(`#EXTM3U
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="keys/k.bin",IV=0x66656463626139383736353433323130,KEYFORMAT="identity"
+#EXTINF:4,
+a.ts
+#EXT-X-ENDLIST
+`),
+		"/v/keys/k.bin": testKey,
+		"/v/a.ts":       fixture.encrypted,
+	}, &keyHits)
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	if e := DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	content, e := ioutil.ReadFile(filepath.Join(dir, "out_go_temp", "00000_a.ts"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	types, streams := demuxTS(t, content)
+	for pid, expected := range fixture.types {
+		if types[pid] != expected {
+			t.Errorf("PID %#x: got stream type %#x, want %#x", pid, types[pid], expected)
+		}
+		if !bytes.Equal(streams[pid], fixture.clear[pid]) {
+			t.Errorf("PID %#x: decrypted stream differs from the clear one", pid)
+		}
+	}
+}
+
+func TestDecryptSampleAESRejectsOtherContainers(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("ftyp"), bytes.Repeat([]byte{tsSyncByte}, tsPacketSize+1)} {
+		if _, e := decryptSampleAES(testKey, testIV, data); e != ErrUnsupportedEncryption {
+			t.Errorf("%d bytes: got %v, want ErrUnsupportedEncryption", len(data), e)
+		}
+	}
+}
+
+func TestEmulationPrevention(t *testing.T) {
+	for _, unit := range [][]byte{
+		{0x65, 0, 0, 0, 0, 1, 0, 0, 2, 0, 0, 3, 0, 0, 4},
+		{0x65, 0, 0},
+		{0x65, 0, 0, 0, 0, 0, 0},
+	} {
+		escaped := appendEmulationPrevention(nil, unit)
+		if bytes.Contains(escaped, []byte{0, 0, 0}) || bytes.Contains(escaped, []byte{0, 0, 1}) ||
+			bytes.Contains(escaped, []byte{0, 0, 2}) || escaped[len(escaped)-1] == 0 {
+			t.Errorf("%x escaped to %x", unit, escaped)
+		}
+		if unescaped := removeEmulationPrevention(escaped); !bytes.Equal(unescaped, unit) {
+			t.Errorf("%x unescaped to %x", escaped, unescaped)
+		}
+	}
+}
+
+func BenchmarkDecryptSampleAES(b *testing.B) {
+	fixture := newSampleAESFixture(rand.New(rand.NewSource(2)), 200)
+	data := make([]byte, len(fixture.encrypted))
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		copy(data, fixture.encrypted)
+		if _, e := decryptSampleAES(testKey, testIV, data); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkDecryptAES128(b *testing.B) {
+	plain := make([]byte, 1<<20)
+	rand.New(rand.NewSource(3)).Read(plain)
+	encrypted := encryptAES128(testKey, testIV, plain)
+	data := make([]byte, len(encrypted))
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		copy(data, encrypted)
+		if _, e := decryptAES128(testKey, testIV, data); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package utility
+
+import (
+	"fmt"
+	"go-utils/src/concurrency"
+	"go-utils/src/hls"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HLSOptions configures DownloadHLSWithOptions, zero fields take the defaults
+type HLSOptions struct {
+	// Concurrency is the number of segments downloaded at once, 4 by default
+	Concurrency int
+	// Retries is the number of extra attempts for a failing segment, 2 by default, negative for none
+	Retries int
+	// The wait before the n-th retry is BaseBackoff * 2^(n-1), capped at MaxBackoff, with jitter.
+	// They default to 500 milliseconds and 10 seconds.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+	// Progress is called after every segment left to download, successful or not, calls never overlap
+	Progress func(segment string, e error, done, total int)
+	// Variant picks the variant of a master playlist to download, the highest bandwidth by default
+	Variant hls.VariantSelector
+	// RenditionTypes lists the EXT-X-MEDIA types whose renditions in the groups of the selected variant
+	// are downloaded too. Nil stands for AUDIO and SUBTITLES, an empty slice for none.
+	RenditionTypes []string
+	// Languages keeps only the renditions in these languages, "en" matching "en-US" as well.
+	// Renditions in every language are downloaded by default.
+	Languages []string
+}
+
+// wantRendition tells whether rendition is to be downloaded with the variant
+func (options HLSOptions) wantRendition(rendition *hls.Rendition) bool {
+	wanted := false
+	for _, renditionType := range options.RenditionTypes {
+		wanted = wanted || renditionType == rendition.Type
+	}
+	if !wanted || len(options.Languages) == 0 {
+		return wanted
+	}
+	language := strings.ToLower(rendition.Language)
+	for _, l := range options.Languages {
+		l = strings.ToLower(l)
+		if language == l || strings.HasPrefix(language, l+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (options HLSOptions) withDefaults() HLSOptions {
+	if options.Concurrency < 1 {
+		options.Concurrency = 4
+	}
+	if options.Retries == 0 {
+		options.Retries = 2
+	} else if options.Retries < 0 {
+		options.Retries = 0
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = 500 * time.Millisecond
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 10 * time.Second
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	if options.Variant == nil {
+		options.Variant = hls.HighestBandwidth()
+	}
+	if options.RenditionTypes == nil {
+		options.RenditionTypes = []string{"AUDIO", "SUBTITLES"}
+	}
+	return options
+}
+
+// backoff returns the wait before retry attempt, half of it fixed and half of it random
+func (options HLSOptions) backoff(attempt int) time.Duration {
+	delay := options.MaxBackoff
+	if attempt <= 30 {
+		if d := options.BaseBackoff << uint(attempt-1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+type SegmentError struct {
+	Segment string
+	Err     error
+}
+
+// HLSDownloadError lists the segments which still failed after every retry
+type HLSDownloadError struct {
+	Failed []SegmentError
+	Total  int
+}
+
+func (e *HLSDownloadError) Error() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("hls: %d of %d segments failed", len(e.Failed), e.Total))
+	for i := range e.Failed {
+		builder.WriteString(fmt.Sprintf("\n\t%s: %v", e.Failed[i].Segment, e.Failed[i].Err))
+	}
+	return builder.String()
+}
+
+// segmentFileName is the name a segment is saved under, without directories and query
+func segmentFileName(segment string) string {
+	if i := strings.IndexByte(segment, '?'); i >= 0 {
+		segment = segment[:i]
+	}
+	return path.Base(segment)
+}
+
+// localSegmentName is the name of the number-th file of a media playlist. The number keeps apart segments
+// differing only in their query or directories, and ranges of one resource.
+func localSegmentName(number int, uri string) string {
+	return fmt.Sprintf("%05d_%s", number, segmentFileName(uri))
+}
+
+// downloadSegment writes segment into to and returns the size written. It goes through a temporary file,
+// so a failed or truncated attempt leaves nothing behind. A non-nil decrypt gets the whole body at once.
+func downloadSegment(client *http.Client, segment *hlsSegment, from, to string, decrypt func([]byte) ([]byte, error)) (int64, error) {
+	request, e := http.NewRequest("GET", from, nil)
+	if e != nil {
+		return 0, e
+	}
+	if segment.Length > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", segment.Offset, segment.Offset+segment.Length-1))
+	}
+	response, e := client.Do(request)
+	if e != nil {
+		return 0, e
+	}
+	defer response.Body.Close()
+
+	var body io.Reader = response.Body
+	expected := response.ContentLength
+	if response.StatusCode == http.StatusOK && segment.Length > 0 {
+		// The server ignored the range and sends the whole resource
+		if _, e := io.CopyN(ioutil.Discard, response.Body, segment.Offset); e != nil {
+			return 0, e
+		}
+		body, expected = io.LimitReader(response.Body, segment.Length), segment.Length
+	} else if response.StatusCode != http.StatusOK && !(response.StatusCode == http.StatusPartialContent && segment.Length > 0) {
+		return 0, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	file, e := os.Create(to + ".part")
+	if e != nil {
+		return 0, e
+	}
+	var size int64
+	var content []byte
+	if decrypt == nil {
+		size, e = io.Copy(file, body)
+	} else {
+		if content, e = ioutil.ReadAll(body); e == nil {
+			size = int64(len(content))
+		}
+	}
+	if e == nil && expected >= 0 && size != expected {
+		e = fmt.Errorf("truncated to %d of %d bytes", size, expected)
+	}
+	if e == nil && decrypt != nil {
+		var plain []byte
+		if plain, e = decrypt(content); e == nil {
+			var written int
+			written, e = file.Write(plain)
+			size = int64(written)
+		}
+	}
+	if e != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return 0, e
+	}
+	if e := file.Close(); e != nil {
+		os.Remove(file.Name())
+		return 0, e
+	}
+	return size, os.Rename(file.Name(), to)
+}
+
+// fetchSegment downloads segment with retries. A panic, like one of a custom transport,
+// fails the segment instead of leaving it without an error.
+func fetchSegment(segment *hlsSegment, uri, target string, decrypt func([]byte) ([]byte, error), options HLSOptions) (size int64, e error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			size, e = 0, fmt.Errorf("hls: segment download panicked: %v", recovered)
+		}
+	}()
+	size, e = downloadSegment(options.Client, segment, uri, target, decrypt)
+	// Retrying cannot help a stream this package does not know how to decrypt
+	for attempt := 1; e != nil && e != ErrUnsupportedEncryption && attempt <= options.Retries; attempt++ {
+		time.Sleep(options.backoff(attempt))
+		size, e = downloadSegment(options.Client, segment, uri, target, decrypt)
+	}
+	return size, e
+}
+
+// manifestSaveInterval limits how often the manifest is rewritten while segments complete
+const manifestSaveInterval = time.Second
+
+// downloadSegments fetches the incomplete segments of manifest into directory,
+// on a pool of options.Concurrency workers, and keeps the manifest on disk up to date
+func downloadSegments(manifest *hlsManifest, directory string, options HLSOptions) error {
+	pending := manifest.pending(directory)
+	keys := newHLSKeyCache(options.Client)
+	pool := concurrency.NewRoutinesPool(options.Concurrency)
+	defer pool.Close()
+
+	var mutex sync.Mutex
+	errs := make([]error, len(manifest.Segments))
+	done := 0
+	saved := time.Now()
+	latch := concurrency.NewCountDownLatch(len(pending))
+	for _, i := range pending {
+		segment := &manifest.Segments[i]
+		// Manifests written before URIs were resolved hold them relative to the playlist
+		uri, e := hls.ResolveURI(manifest.URL, segment.URI)
+		if e != nil {
+			return e
+		}
+		target := filepath.Join(directory, filepath.FromSlash(segment.File))
+		var decrypt func([]byte) ([]byte, error)
+		if segment.Key != nil {
+			decrypt = keys.decrypter(segment.Key, segment.Sequence)
+		}
+		i := i
+		task := func() {
+			defer latch.CountDown()
+			size, e := fetchSegment(&segment.hlsSegment, uri, target, decrypt, options)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			done++
+			errs[i] = e
+			if e == nil {
+				segment.Size = size
+				segment.Complete = true
+				// Losing the manifest only costs downloading a few segments again
+				if time.Since(saved) >= manifestSaveInterval {
+					if manifest.save(directory) == nil {
+						saved = time.Now()
+					}
+				}
+			}
+			if options.Progress != nil {
+				options.Progress(segment.URI, e, done, len(pending))
+			}
+		}
+		if e := pool.Submit(task); e != nil {
+			return e
+		}
+	}
+	latch.Await()
+
+	if e := manifest.save(directory); e != nil {
+		return e
+	}
+	failed := make([]SegmentError, 0)
+	for i := range errs {
+		if errs[i] != nil {
+			failed = append(failed, SegmentError{manifest.Segments[i].URI, errs[i]})
+		}
+	}
+	if len(failed) > 0 {
+		return &HLSDownloadError{failed, len(manifest.Segments)}
+	}
+	return nil
+}
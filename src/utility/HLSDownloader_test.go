@@ -0,0 +1,366 @@
+package utility
+
+import (
+	"fmt"
+	"go-utils/src/hls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadHLSKeepsSegmentsApart(t *testing.T) {
+	whole := strings.Repeat("0123456789", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v/index.m3u8":
+			w.Write([]byte(`#EXTM3U
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4,
+seg.ts?n=1
+#EXTINF:4,
+seg.ts?n=2
+#EXTINF:4,
+a/0.ts
+#EXTINF:4,
+b/0.ts
+#EXTINF:4,
+#EXT-X-BYTERANGE:100@0
+whole.ts
+#EXTINF:4,
+#EXT-X-BYTERANGE:250
+whole.ts
+#EXT-X-ENDLIST
+`))
+		case "/v/whole.ts":
+			http.ServeContent(w, r, "whole.ts", time.Time{}, strings.NewReader(whole))
+		default:
+			w.Write([]byte(r.URL.RequestURI()))
+		}
+	}))
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	if e := DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	temp := filepath.Join(dir, "out_go_temp")
+	expected := []struct {
+		file, content string
+	}{
+		{"00000_init.mp4", "/v/init.mp4"},
+		{"00001_seg.ts", "/v/seg.ts?n=1"},
+		{"00002_seg.ts", "/v/seg.ts?n=2"},
+		{"00003_0.ts", "/v/a/0.ts"},
+		{"00004_0.ts", "/v/b/0.ts"},
+		{"00005_whole.ts", whole[:100]},
+		{"00006_whole.ts", whole[100:350]},
+	}
+	index, e := ioutil.ReadFile(filepath.Join(temp, "index.m3u8"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, file := range expected {
+		if content, _ := ioutil.ReadFile(filepath.Join(temp, file.file)); string(content) != file.content {
+			t.Errorf("%s: got %q, want %q", file.file, content, file.content)
+		}
+		if !strings.Contains(string(index), file.file) {
+			t.Errorf("local playlist does not refer to %s:\n%s", file.file, index)
+		}
+	}
+	if strings.Contains(string(index), "BYTERANGE") {
+		t.Errorf("local playlist still has byte ranges:\n%s", index)
+	}
+	if missing, e := CompareHLS(filepath.Join(temp, "index.m3u8"), temp); e != nil || len(missing) != 0 {
+		t.Errorf("CompareHLS: got %v, %v, want nothing missing", missing, e)
+	}
+}
+
+func TestDownloadHLSRetriesAndResumes(t *testing.T) {
+	var mutex sync.Mutex
+	broken := true
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		hits[r.URL.Path]++
+		count, broken := hits[r.URL.Path], broken
+		mutex.Unlock()
+		switch {
+		case r.URL.Path == "/v/index.m3u8":
+			var builder strings.Builder
+			builder.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:10\n")
+			for i := 0; i < 20; i++ {
+				fmt.Fprintf(&builder, "#EXTINF:10,\nseg%d.ts?token=1\n", i)
+			}
+			builder.WriteString("#EXT-X-ENDLIST\n")
+			w.Write([]byte(builder.String()))
+		case r.URL.Path == "/v/seg3.ts" && count < 3, r.URL.Path == "/v/seg7.ts" && broken:
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	temp := filepath.Join(dir, "out_go_temp")
+
+	progress := 0
+	e = DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{
+		BaseBackoff: time.Millisecond,
+		Progress:    func(segment string, e error, done, total int) { progress = done },
+	})
+	failure, ok := e.(*HLSDownloadError)
+	if !ok || len(failure.Failed) != 1 || !strings.HasSuffix(failure.Failed[0].Segment, "/v/seg7.ts?token=1") {
+		t.Fatalf("got %v, want seg7.ts to fail", e)
+	}
+	if progress != 20 {
+		t.Errorf("progress reached %d of 20 segments", progress)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(temp, "00003_seg3.ts")); string(content) != "/v/seg3.ts" {
+		t.Errorf("seg3.ts was not retried: got %q", content)
+	}
+
+	// A truncated segment and the failed one are all that is fetched again
+	mutex.Lock()
+	if hits["/v/seg7.ts"] != 3 {
+		t.Errorf("seg7.ts fetched %d times, want 3", hits["/v/seg7.ts"])
+	}
+	hits = make(map[string]int)
+	broken = false
+	mutex.Unlock()
+	if e := ioutil.WriteFile(filepath.Join(temp, "00005_seg5.ts"), []byte("x"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if missing, e := CompareHLS(filepath.Join(temp, "index.m3u8"), temp); e != nil || len(missing) != 2 {
+		t.Fatalf("CompareHLS: got %v, %v, want seg5.ts and seg7.ts", missing, e)
+	}
+	if e := DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{}); e != nil {
+		t.Fatal(e)
+	}
+	mutex.Lock()
+	if len(hits) != 2 || hits["/v/seg5.ts"] != 1 || hits["/v/seg7.ts"] != 1 {
+		t.Errorf("resuming fetched %v, want seg5.ts and seg7.ts once", hits)
+	}
+	mutex.Unlock()
+	if missing, e := CompareHLS(filepath.Join(temp, "index.m3u8"), temp); e != nil || len(missing) != 0 {
+		t.Errorf("CompareHLS: got %v, %v, want nothing missing", missing, e)
+	}
+}
+
+func TestLocalSegmentName(t *testing.T) {
+	for _, test := range []struct {
+		number        int
+		uri, expected string
+	}{
+		{0, "seg.ts", "00000_seg.ts"},
+		{1, "http://example.com/a/seg.ts?n=1", "00001_seg.ts"},
+		{12345, "http://example.com/a/b/c.m4s", "12345_c.m4s"},
+	} {
+		if name := localSegmentName(test.number, test.uri); name != test.expected {
+			t.Errorf("localSegmentName(%d, %q) = %q, want %q", test.number, test.uri, name, test.expected)
+		}
+	}
+}
+
+// authorizingTransport adds the token the test servers expect to every request
+type authorizingTransport struct{}
+
+func (authorizingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer token")
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestDownloadHLSFetchesPlaylistsWithClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/m/master.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2\nhigh.m3u8\n"))
+		case "/m/low.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg.ts\n#EXT-X-ENDLIST\n"))
+		case "/m/seg.ts":
+			w.Write([]byte(r.URL.Path))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	client := &http.Client{Transport: authorizingTransport{}}
+
+	if e := DownloadHLSWithOptions(server.URL+"/m/master.m3u8", filepath.Join(dir, "default"), HLSOptions{}); e == nil ||
+		!strings.Contains(e.Error(), "403") {
+		t.Errorf("without the client: got %v, want the 403 of the playlist", e)
+	}
+	options := HLSOptions{Client: client, Variant: hls.LowestBandwidth()}
+	if e := DownloadHLSWithOptions(server.URL+"/m/master.m3u8", filepath.Join(dir, "low"), options); e != nil {
+		t.Fatal(e)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "low_go_temp", "stream", "00000_seg.ts")); string(content) != "/m/seg.ts" {
+		t.Errorf("got segment %q", content)
+	}
+	// The variant playlist of the highest bandwidth is missing
+	options.Variant = hls.HighestBandwidth()
+	if e := DownloadHLSWithOptions(server.URL+"/m/master.m3u8", filepath.Join(dir, "high"), options); e == nil ||
+		!strings.Contains(e.Error(), "404") {
+		t.Errorf("missing variant playlist: got %v, want its 404", e)
+	}
+}
+
+// panickingTransport panics on requests for the path, like a broken custom transport
+type panickingTransport string
+
+func (path panickingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Path == string(path) {
+		panic("broken transport")
+	}
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestDownloadHLSSegmentPanics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v/index.m3u8" {
+			w.Write([]byte("#EXTM3U\n#EXTINF:4,\ngood.ts\n#EXTINF:4,\nbad.ts\n#EXT-X-ENDLIST\n"))
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	e = DownloadHLSWithOptions(server.URL+"/v/index.m3u8", filepath.Join(dir, "out"), HLSOptions{
+		Client: &http.Client{Transport: panickingTransport("/v/bad.ts")},
+	})
+	failure, ok := e.(*HLSDownloadError)
+	if !ok || len(failure.Failed) != 1 || !strings.HasSuffix(failure.Failed[0].Segment, "/v/bad.ts") ||
+		!strings.Contains(failure.Failed[0].Err.Error(), "broken transport") {
+		t.Fatalf("got %v, want bad.ts to fail with the panic", e)
+	}
+	if missing, e := CompareHLS(filepath.Join(dir, "out_go_temp", "index.m3u8"), filepath.Join(dir, "out_go_temp")); e != nil ||
+		len(missing) != 1 {
+		t.Errorf("CompareHLS: got %v, %v, want bad.ts missing", missing, e)
+	}
+}
+
+func TestDownloadHLSMasterRenditions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/m/master.m3u8":
+			w.Write([]byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="a",NAME="en",LANGUAGE="en-US",URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="a",NAME="fr",LANGUAGE="fr",URI="audio/fr.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="s",NAME="en",LANGUAGE="en",URI="subs/en.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="cc",INSTREAM-ID="CC1"
+#EXT-X-STREAM-INF:BANDWIDTH=1,RESOLUTION=640x360,CODECS="avc1.4d401e",AUDIO="a",SUBTITLES="s",CLOSED-CAPTIONS="cc"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2,RESOLUTION=1920x1080,CODECS="hvc1.1",CLOSED-CAPTIONS=NONE
+high/index.m3u8
+`))
+		case "/m/low/index.m3u8", "/m/high/index.m3u8", "/m/audio/en.m3u8", "/m/audio/fr.m3u8", "/m/subs/en.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\npart0.ts\n#EXT-X-ENDLIST\n"))
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+	defer server.Close()
+	dir, e := ioutil.TempDir("", "hls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	// download returns the local master playlist
+	download := func(name string, options HLSOptions) *hls.MasterPlaylist {
+		t.Helper()
+		if e := DownloadHLSWithOptions(server.URL+"/m/master.m3u8", filepath.Join(dir, name), options); e != nil {
+			t.Fatal(e)
+		}
+		temp := filepath.Join(dir, name+"_go_temp")
+		if missing, e := CompareHLS(filepath.Join(temp, "master.m3u8"), temp); e != nil || len(missing) != 0 {
+			t.Errorf("%s: CompareHLS: got %v, %v, want nothing missing", name, missing, e)
+		}
+		content, e := ioutil.ReadFile(filepath.Join(temp, "master.m3u8"))
+		if e != nil {
+			t.Fatal(e)
+		}
+		playlist, e := hls.ParseString(string(content))
+		if e != nil {
+			t.Fatal(e)
+		}
+		return playlist.(*hls.MasterPlaylist)
+	}
+
+	// The default variant has no closed captions at all, which must stay explicit
+	master := download("default", HLSOptions{})
+	if len(master.Variants) != 1 || master.Variants[0].URI != "stream/index.m3u8" || len(master.Renditions) != 0 {
+		t.Fatalf("got variants %+v and renditions %+v", master.Variants, master.Renditions)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "default_go_temp", "stream", "00000_part0.ts")); string(content) != "/m/high/part0.ts" {
+		t.Errorf("default variant: got segment %q", content)
+	}
+	if captions := master.Variants[0].ClosedCaptions; captions != "NONE" {
+		t.Errorf("CLOSED-CAPTIONS=NONE became %q", captions)
+	}
+
+	// English audio only, the subtitles group is dropped and the muxed captions are kept
+	master = download("filtered", HLSOptions{
+		Variant:        hls.WithCodec("avc1", hls.HighestBandwidth()),
+		RenditionTypes: []string{"AUDIO"},
+		Languages:      []string{"EN"},
+	})
+	variant := master.Variants[0]
+	if variant.Audio != "a" || variant.Subtitles != "" || variant.ClosedCaptions != "cc" {
+		t.Errorf("got groups audio %q, subtitles %q and closed captions %q", variant.Audio, variant.Subtitles, variant.ClosedCaptions)
+	}
+	if len(master.Renditions) != 2 || master.Renditions[0].URI != "audio0/en.m3u8" || master.Renditions[1].Type != "CLOSED-CAPTIONS" {
+		t.Errorf("got renditions %+v", master.Renditions)
+	}
+	temp := filepath.Join(dir, "filtered_go_temp")
+	for file, expected := range map[string]string{
+		"stream/00000_part0.ts": "/m/low/part0.ts",
+		"audio0/00000_part0.ts": "/m/audio/part0.ts",
+	} {
+		if content, _ := ioutil.ReadFile(filepath.Join(temp, filepath.FromSlash(file))); string(content) != expected {
+			t.Errorf("%s: got %q, want %q", file, content, expected)
+		}
+	}
+	for _, skipped := range []string{"audio1", "subtitles0"} {
+		if _, e := os.Stat(filepath.Join(temp, skipped)); !os.IsNotExist(e) {
+			t.Errorf("%s was downloaded: %v", skipped, e)
+		}
+	}
+
+	// The closest resolution picks the variant
+	master = download("closest", HLSOptions{Variant: hls.ClosestResolution(1920, 1080)})
+	if master.Variants[0].Resolution.Height != 1080 {
+		t.Errorf("got variant %+v, want the 1080p one", master.Variants[0])
+	}
+	if e := DownloadHLSWithOptions(server.URL+"/m/master.m3u8", filepath.Join(dir, "none"),
+		HLSOptions{Variant: hls.WithCodec("vp09", hls.LowestBandwidth())}); e == nil || !strings.Contains(e.Error(), "no variant") {
+		t.Errorf("got %v, want no variant to match", e)
+	}
+}
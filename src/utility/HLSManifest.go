@@ -0,0 +1,83 @@
+package utility
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// hlsManifestFile is kept in the temporary directory of a download so an interrupted one can be resumed
+const hlsManifestFile = "manifest.json"
+
+// hlsSegment is a file to download: a media segment or an initialization section.
+// File is where it is saved, relative to the temporary directory, and a positive Length selects a byte range.
+type hlsSegment struct {
+	URI      string  `json:"uri"`
+	File     string  `json:"file"`
+	Offset   int64   `json:"offset,omitempty"`
+	Length   int64   `json:"length,omitempty"`
+	Sequence int64   `json:"sequence"`
+	Key      *hlsKey `json:"key,omitempty"`
+}
+
+type hlsManifestSegment struct {
+	hlsSegment
+	Size     int64 `json:"size"`
+	Complete bool  `json:"complete"`
+}
+
+type hlsManifest struct {
+	URL      string               `json:"url"`
+	Segments []hlsManifestSegment `json:"segments"`
+}
+
+func newHLSManifest(url string, segments []hlsSegment) *hlsManifest {
+	manifest := &hlsManifest{URL: url, Segments: make([]hlsManifestSegment, len(segments))}
+	for i := range segments {
+		manifest.Segments[i] = hlsManifestSegment{hlsSegment: segments[i]}
+	}
+	return manifest
+}
+
+func loadHLSManifest(directory string) (*hlsManifest, error) {
+	content, e := ioutil.ReadFile(filepath.Join(directory, hlsManifestFile))
+	if e != nil {
+		return nil, e
+	}
+	manifest := new(hlsManifest)
+	if e := json.Unmarshal(content, manifest); e != nil {
+		return nil, e
+	}
+	return manifest, nil
+}
+
+// save replaces the manifest in one rename, so an interruption never leaves half of it behind
+func (manifest *hlsManifest) save(directory string) error {
+	content, e := json.MarshalIndent(manifest, "", "  ")
+	if e != nil {
+		return e
+	}
+	target := filepath.Join(directory, hlsManifestFile)
+	if e := ioutil.WriteFile(target+".part", content, 0644); e != nil {
+		return e
+	}
+	return os.Rename(target+".part", target)
+}
+
+// pending marks the segments whose file is missing or has another size than recorded as incomplete
+// and returns their indexes
+func (manifest *hlsManifest) pending(directory string) []int {
+	result := make([]int, 0)
+	for i := range manifest.Segments {
+		segment := &manifest.Segments[i]
+		if segment.Complete {
+			info, e := os.Stat(filepath.Join(directory, filepath.FromSlash(segment.File)))
+			segment.Complete = e == nil && info.Size() == segment.Size
+		}
+		if !segment.Complete {
+			result = append(result, i)
+		}
+	}
+	return result
+}
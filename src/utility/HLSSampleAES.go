@@ -0,0 +1,451 @@
+package utility
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+// SAMPLE-AES in MPEG-2 transport streams encrypts the H.264 slices and the AAC, AC-3 or E-AC-3 frames,
+// each sample restarting AES-128-CBC from the IV, and leaves the rest of the stream clear.
+// Fragmented MP4 segments use the cbcs scheme of Common Encryption instead, which is not supported.
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+)
+
+// sampleAESStreamTypes maps the stream types announcing encrypted streams in the PMT to the clear ones
+var sampleAESStreamTypes = map[byte]byte{
+	0xdb: 0x1b, // H.264
+	0xcf: 0x0f, // AAC in ADTS
+	0xc1: 0x81, // AC-3
+	0xc2: 0x87, // E-AC-3
+}
+
+var errBrokenTransportStream = errors.New("hls: broken transport stream")
+
+// tsPES is a PES packet of an encrypted stream, spread over the payloads of packets
+type tsPES struct {
+	packets []int
+	content []byte
+}
+
+// decryptSampleAES decrypts a transport stream segment, announces its streams as clear in the PMT
+// and packs the PES packets again, as decryption may change the size of H.264 slices
+func decryptSampleAES(key, iv, data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%tsPacketSize != 0 || data[0] != tsSyncByte {
+		return nil, ErrUnsupportedEncryption
+	}
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+
+	count := len(data) / tsPacketSize
+	pmts := make(map[int]bool)
+	streams := make(map[int]byte)
+	current := make(map[int]*tsPES)
+	packets := make([]*tsPES, 0)
+	for i := 0; i < count; i++ {
+		packet := data[i*tsPacketSize : (i+1)*tsPacketSize]
+		if packet[0] != tsSyncByte {
+			return nil, errBrokenTransportStream
+		}
+		offset, e := tsPayloadOffset(packet)
+		if e != nil {
+			return nil, e
+		}
+		pid, start := tsPID(packet), packet[1]&0x40 != 0
+		switch {
+		case offset == tsPacketSize:
+		case pid == 0 && start:
+			if e := parsePAT(packet[offset:], pmts); e != nil {
+				return nil, e
+			}
+		case pmts[pid] && start:
+			if e := rewritePMT(packet[offset:], streams); e != nil {
+				return nil, e
+			}
+		case streams[pid] != 0:
+			pes := current[pid]
+			if start {
+				pes = &tsPES{}
+				current[pid] = pes
+				packets = append(packets, pes)
+			}
+			// Leftovers of a PES packet started in the previous segment are kept as they are
+			if pes != nil {
+				pes.packets = append(pes.packets, i)
+				pes.content = append(pes.content, packet[offset:]...)
+			}
+		}
+	}
+
+	// Every packet carrying a PES packet is replaced by the packets of the decrypted one
+	replaced := make(map[int][][]byte)
+	for _, pes := range packets {
+		first := data[pes.packets[0]*tsPacketSize:]
+		content, e := decryptPES(block, iv, pes.content, streams[tsPID(first)])
+		if e != nil {
+			return nil, e
+		}
+		output := packPES(data, pes.packets, content)
+		last := len(pes.packets) - 1
+		for j, i := range pes.packets {
+			switch {
+			case j >= len(output):
+				replaced[i] = nil
+			case j == last:
+				replaced[i] = output[j:]
+			default:
+				replaced[i] = output[j : j+1]
+			}
+		}
+	}
+
+	result := make([]byte, 0, len(data)+len(data)/8)
+	counters := make(map[int]byte)
+	for i := 0; i < count; i++ {
+		packet := data[i*tsPacketSize : (i+1)*tsPacketSize]
+		output, exist := replaced[i]
+		if !exist {
+			output = [][]byte{packet}
+		}
+		for _, packet := range output {
+			pid := tsPID(packet)
+			if streams[pid] != 0 {
+				// Continuity counters only advance on packets with a payload
+				counter, seen := counters[pid]
+				if !seen {
+					counter = packet[3] & 0x0f
+				} else if packet[3]&0x10 != 0 {
+					counter = (counter + 1) & 0x0f
+				}
+				counters[pid] = counter
+				packet[3] = packet[3]&0xf0 | counter
+			}
+			result = append(result, packet...)
+		}
+	}
+	return result, nil
+}
+
+func tsPID(packet []byte) int {
+	return int(packet[1]&0x1f)<<8 | int(packet[2])
+}
+
+// tsPayloadOffset returns where the payload of packet starts, tsPacketSize when it has none
+func tsPayloadOffset(packet []byte) (int, error) {
+	offset := 4
+	if packet[3]&0x20 != 0 {
+		offset += 1 + int(packet[4])
+	}
+	if packet[3]&0x10 == 0 {
+		return tsPacketSize, nil
+	}
+	if offset > tsPacketSize {
+		return 0, errBrokenTransportStream
+	}
+	return offset, nil
+}
+
+// psiSection returns the section starting in payload, after its pointer field
+func psiSection(payload []byte) ([]byte, error) {
+	if len(payload) < 1 || 1+int(payload[0])+3 > len(payload) {
+		return nil, errBrokenTransportStream
+	}
+	section := payload[1+int(payload[0]):]
+	length := 3 + (int(section[1]&0x0f)<<8 | int(section[2]))
+	if length > len(section) {
+		return nil, errors.New("hls: program tables spanning several packets are not supported")
+	}
+	if length < 12 {
+		return nil, errBrokenTransportStream
+	}
+	return section[:length], nil
+}
+
+// parsePAT adds the PIDs of the PMTs listed by the PAT starting in payload to pmts
+func parsePAT(payload []byte, pmts map[int]bool) error {
+	section, e := psiSection(payload)
+	if e != nil {
+		return e
+	}
+	for i := 8; i+4 <= len(section)-4; i += 4 {
+		// Program 0 points to the network information table
+		if section[i] != 0 || section[i+1] != 0 {
+			pmts[int(section[i+2]&0x1f)<<8|int(section[i+3])] = true
+		}
+	}
+	return nil
+}
+
+// rewritePMT replaces the encrypted stream types of the PMT starting in payload by the clear ones,
+// adds the encrypted streams to streams and updates the CRC
+func rewritePMT(payload []byte, streams map[int]byte) error {
+	section, e := psiSection(payload)
+	if e != nil {
+		return e
+	}
+	end := len(section) - 4
+	for i := 12 + (int(section[10]&0x0f)<<8 | int(section[11])); i+5 <= end; {
+		if clear, exist := sampleAESStreamTypes[section[i]]; exist {
+			streams[int(section[i+1]&0x1f)<<8|int(section[i+2])] = section[i]
+			section[i] = clear
+		}
+		i += 5 + (int(section[i+3]&0x0f)<<8 | int(section[i+4]))
+	}
+	crc := mpegCRC32(section[:end])
+	section[end], section[end+1], section[end+2], section[end+3] = byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc)
+	return nil
+}
+
+// mpegCRC32 is the CRC of program specific information sections
+func mpegCRC32(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// decryptPES decrypts the elementary stream data of a PES packet of the given encrypted stream type
+func decryptPES(block cipher.Block, iv, pes []byte, streamType byte) ([]byte, error) {
+	if len(pes) < 9 || pes[0] != 0 || pes[1] != 0 || pes[2] != 1 || 9+int(pes[8]) > len(pes) {
+		return nil, errors.New("hls: broken PES packet")
+	}
+	header := 9 + int(pes[8])
+	var e error
+	switch streamType {
+	case 0xdb:
+		pes = append(pes[:header:header], decryptH264(block, iv, pes[header:])...)
+	case 0xcf:
+		e = decryptAudio(block, iv, pes[header:], adtsFrame)
+	default:
+		e = decryptAudio(block, iv, pes[header:], ac3Frame)
+	}
+	if e != nil {
+		return nil, e
+	}
+	// A length of 0 leaves it unbounded, which only video streams may do
+	if pes[4] != 0 || pes[5] != 0 {
+		length := len(pes) - 6
+		if length > 0xffff {
+			length = 0
+		}
+		pes[4], pes[5] = byte(length>>8), byte(length)
+	}
+	return pes, nil
+}
+
+// packPES splits pes into packets, reusing the headers of the packets it came in.
+// Adaptation fields keep their flags, PCR and other fields, and stuffing fills the last packet.
+func packPES(data []byte, packets []int, pes []byte) [][]byte {
+	output := make([][]byte, 0, len(packets)+1)
+	var continuation []byte
+	for i := 0; len(pes) > 0; i++ {
+		var header []byte
+		if i < len(packets) {
+			header = tsHeader(data[packets[i]*tsPacketSize : (packets[i]+1)*tsPacketSize])
+		} else {
+			if continuation == nil {
+				first := data[packets[0]*tsPacketSize:]
+				continuation = []byte{first[0], first[1] &^ 0x40, first[2], 0x10 | first[3]&0xcf}
+			}
+			header = append([]byte(nil), continuation...)
+		}
+		if room := tsPacketSize - len(header); len(pes) < room {
+			header = stuffAdaptationField(header, room-len(pes))
+		}
+		size := tsPacketSize - len(header)
+		output = append(output, append(header, pes[:size]...))
+		pes = pes[size:]
+	}
+	return output
+}
+
+// tsHeader copies the header and the adaptation field of packet, without the stuffing bytes
+func tsHeader(packet []byte) []byte {
+	if packet[3]&0x20 == 0 || packet[4] == 0 {
+		return append(make([]byte, 0, tsPacketSize), packet[:4+int(packet[3]>>5&1)]...)
+	}
+	field := packet[5 : 5+int(packet[4])]
+	size := 1
+	for _, optional := range []struct {
+		flag byte
+		size int
+	}{{0x10, 6}, {0x08, 6}, {0x04, 1}, {0x02, -1}, {0x01, -1}} {
+		if field[0]&optional.flag == 0 {
+			continue
+		}
+		// Private data and the extension start with their length
+		if optional.size < 0 && size < len(field) {
+			size += 1 + int(field[size])
+		} else {
+			size += optional.size
+		}
+	}
+	if size > len(field) {
+		size = len(field)
+	}
+	header := append(make([]byte, 0, tsPacketSize), packet[:5+size]...)
+	header[4] = byte(size)
+	return header
+}
+
+// stuffAdaptationField grows the adaptation field of header by n bytes, adding one when there is none
+func stuffAdaptationField(header []byte, n int) []byte {
+	if header[3]&0x20 == 0 {
+		header[3] |= 0x20
+		header = append(header, 0)
+		n--
+	}
+	if n > 0 && header[4] == 0 {
+		header = append(header, 0)
+		header[4]++
+		n--
+	}
+	for ; n > 0; n-- {
+		header = append(header, 0xff)
+		header[4]++
+	}
+	return header
+}
+
+var h264StartCode = []byte{0, 0, 1}
+
+// decryptH264 decrypts the slices of an Annex B stream. Slices longer than 48 bytes have one block
+// encrypted out of every ten after a clear leader of 32 bytes, without their emulation prevention bytes.
+func decryptH264(block cipher.Block, iv, stream []byte) []byte {
+	output := make([]byte, 0, len(stream))
+	copied := 0
+	for code := bytes.Index(stream, h264StartCode); code >= 0; {
+		start, end, next := code+3, len(stream), -1
+		if i := bytes.Index(stream[start:], h264StartCode); i >= 0 {
+			next = start + i
+			end = next
+		}
+		// Zero bytes before a start code are not part of the NAL unit
+		for end > start && stream[end-1] == 0 {
+			end--
+		}
+		// Only slices, type 1, and slices of IDR pictures, type 5, are encrypted
+		if end-start > 48 && (stream[start]&0x1f == 1 || stream[start]&0x1f == 5) {
+			unit := removeEmulationPrevention(stream[start:end])
+			mode := cipher.NewCBCDecrypter(block, iv)
+			for offset := 32; len(unit)-offset > aes.BlockSize; offset += 10 * aes.BlockSize {
+				mode.CryptBlocks(unit[offset:offset+aes.BlockSize], unit[offset:offset+aes.BlockSize])
+			}
+			output = appendEmulationPrevention(append(output, stream[copied:start]...), unit)
+			copied = end
+		}
+		code = next
+	}
+	return append(output, stream[copied:]...)
+}
+
+// removeEmulationPrevention drops the 3 of every 0x000003 sequence
+func removeEmulationPrevention(unit []byte) []byte {
+	output := make([]byte, 0, len(unit))
+	zeros := 0
+	for _, b := range unit {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		output = append(output, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return output
+}
+
+// appendEmulationPrevention appends unit to output, inserting a 3 wherever two zeros precede a byte below 4
+func appendEmulationPrevention(output, unit []byte) []byte {
+	zeros := 0
+	for _, b := range unit {
+		if zeros >= 2 && b <= 3 {
+			output = append(output, 3)
+			zeros = 0
+		}
+		output = append(output, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	// A unit cannot end with a zero, as a start code could follow
+	if len(unit) > 0 && unit[len(unit)-1] == 0 {
+		output = append(output, 3)
+	}
+	return output
+}
+
+// decryptAudio decrypts in place the frames of an audio stream. The frame function returns the header
+// and total size of the frame starting its argument, 0 when there is none.
+func decryptAudio(block cipher.Block, iv, stream []byte, frame func([]byte) (int, int)) error {
+	for len(stream) > 0 {
+		header, size := frame(stream)
+		if size <= header || size > len(stream) {
+			return errors.New("hls: broken audio frame")
+		}
+		// The first 16 bytes after the header and the last partial block are clear
+		sample := stream[header:size]
+		if n := (len(sample) - 16) / aes.BlockSize * aes.BlockSize; n > 0 {
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(sample[16:16+n], sample[16:16+n])
+		}
+		stream = stream[size:]
+	}
+	return nil
+}
+
+func adtsFrame(stream []byte) (int, int) {
+	if len(stream) < 7 || stream[0] != 0xff || stream[1]&0xf0 != 0xf0 {
+		return 0, 0
+	}
+	header := 7
+	if stream[1]&1 == 0 {
+		header += 2
+	}
+	return header, int(stream[3]&3)<<11 | int(stream[4])<<3 | int(stream[5]>>5)
+}
+
+var ac3Bitrates = [...]int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+
+// ac3Frame sizes AC-3 and E-AC-3 sync frames, which are encrypted header included
+func ac3Frame(stream []byte) (int, int) {
+	if len(stream) < 6 || stream[0] != 0x0b || stream[1] != 0x77 {
+		return 0, 0
+	}
+	// E-AC-3 has a bit stream identification above 10 and gives the size in words
+	if stream[5]>>3 > 10 {
+		return 0, (int(stream[2]&7)<<8 | int(stream[3]) + 1) * 2
+	}
+	code := int(stream[4] & 0x3f)
+	if code/2 >= len(ac3Bitrates) {
+		return 0, 0
+	}
+	bitrate := ac3Bitrates[code/2]
+	switch stream[4] >> 6 {
+	case 0:
+		return 0, bitrate * 4
+	case 1:
+		return 0, (bitrate*960/441 + code&1) * 2
+	case 2:
+		return 0, bitrate * 6
+	}
+	return 0, 0
+}